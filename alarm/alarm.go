@@ -1,58 +1,438 @@
 package alarm
 
 import (
+	"fmt"
 	"log/slog"
-	"sync/atomic"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/SuddenGunter/hsd/audit"
+	"github.com/SuddenGunter/hsd/event"
+	"github.com/SuddenGunter/hsd/notify/message"
 )
 
+// historySize is the number of transitions kept in memory.
+const historySize = 50
+
 type notifier interface {
-	Notify(device, msg string)
+	Notify(m message.Message)
 }
 
-// Alarmer tracks if the alarm is enabled.
-// Right now it is a simple flag, but may be extended in the future with scheduling or other features.
+type recorder interface {
+	Record(e event.Event)
+}
+
+type auditor interface {
+	Audit(e audit.Entry)
+}
+
+type stateObserver interface {
+	AlarmStateChanged()
+}
+
+// Options configure the Alarmer.
+type Options struct {
+	// ArmingDelay is the exit delay between arming the alarm and the alarm becoming armed.
+	ArmingDelay time.Duration
+	// PendingDelay is the entry delay between a device alarm and the alarm going off.
+	PendingDelay time.Duration
+	// DeviceModes lists modes each device is monitored in. Devices without an entry are monitored in all modes.
+	DeviceModes map[string][]Mode
+}
+
+// Alarmer is the alarm state machine.
+// It decides if device alarms should be sent to the notifier based on the current state and arm mode.
 type Alarmer struct {
 	notifier notifier
-	enabled  *atomic.Bool
+	recorder recorder
+	auditor  auditor
+
+	mux     *sync.Mutex
+	state   State
+	mode    Mode
+	history []Transition
+	// seq is incremented on every transition so stale arming/pending timers can detect they were superseded
+	seq   uint64
+	timer *time.Timer
+
+	armingDelay  time.Duration
+	pendingDelay time.Duration
+	deviceModes  map[string][]Mode
+	devices      deviceStates
+
+	obsMux    *sync.Mutex
+	observers []stateObserver
 
 	l *slog.Logger
 }
 
 // New returns a new Alarmer.
-func New(notifier notifier, l *slog.Logger) *Alarmer {
-	// start with alarm enabled on restart
-	e := &atomic.Bool{}
-	e.Store(true)
+func New(notifier notifier, recorder recorder, auditor auditor, opts Options, l *slog.Logger) *Alarmer {
+	deviceModes := make(map[string][]Mode, len(opts.DeviceModes))
+	maps.Copy(deviceModes, opts.DeviceModes)
 
-	return &Alarmer{
-		notifier: notifier,
-		enabled:  e,
-		l:        l,
+	a := &Alarmer{
+		notifier:     notifier,
+		recorder:     recorder,
+		auditor:      auditor,
+		mux:          &sync.Mutex{},
+		state:        StateDisarmed,
+		armingDelay:  opts.ArmingDelay,
+		pendingDelay: opts.PendingDelay,
+		deviceModes:  deviceModes,
+		obsMux:       &sync.Mutex{},
+		l:            l,
 	}
+
+	// start with alarm armed on restart
+	a.mode = ModeAway
+	a.transition(StateArmedAway, Actor{Source: SourceSystem}, "startup")
+
+	return a
 }
 
-// Enabled returns the current state of the alarm.
+// Observe registers an observer that is called after every alarm state change.
+func (a *Alarmer) Observe(o stateObserver) {
+	a.obsMux.Lock()
+	defer a.obsMux.Unlock()
+
+	a.observers = append(a.observers, o)
+}
+
+// State returns the current state of the alarm.
+func (a *Alarmer) State() State {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	return a.state
+}
+
+// Mode returns the mode the alarm was last armed in.
+func (a *Alarmer) Mode() Mode {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	return a.mode
+}
+
+// Enabled returns true unless the alarm is disarmed.
 func (a *Alarmer) Enabled() bool {
-	return a.enabled.Load()
+	return a.State() != StateDisarmed
+}
+
+// History returns recent state transitions, oldest first.
+func (a *Alarmer) History() []Transition {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	h := make([]Transition, len(a.history))
+	copy(h, a.history)
+
+	return h
+}
+
+// SetDeviceModes changes modes the device is monitored in. Empty modes mean the device is monitored in all modes.
+func (a *Alarmer) SetDeviceModes(device string, modes []Mode) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	if len(modes) == 0 {
+		delete(a.deviceModes, device)
+		return
+	}
+
+	a.deviceModes[device] = slices.Clone(modes)
 }
 
-// Enable the alarm.
-func (a *Alarmer) Enable() {
-	a.enabled.Store(true)
-	a.notifier.Notify("alarm", "enabled")
+// Arm the alarm in the given mode. If arming delay is configured, the alarm goes through the arming state first.
+// If readiness is checked and devices monitored in the mode are open or offline, a *NotReadyError is returned.
+// The actor is recorded in the state history and the audit log.
+func (a *Alarmer) Arm(mode Mode, actor Actor) error {
+	return a.arm(mode, actor, false)
 }
 
-// Disable the alarm.
-func (a *Alarmer) Disable() {
-	a.enabled.Store(false)
-	a.notifier.Notify("alarm", "disabled")
+// ForceArm arms the alarm like Arm, but bypasses open or offline devices until the alarm is disarmed.
+func (a *Alarmer) ForceArm(mode Mode, actor Actor) error {
+	return a.arm(mode, actor, true)
 }
 
-// Alarm sends an alarm message to the notifier if the alarm is enabled.
-func (a *Alarmer) Alarm(device, message string) {
-	if a.Enabled() {
-		a.notifier.Notify(device, message)
+func (a *Alarmer) arm(mode Mode, actor Actor, force bool) error {
+	notReady := a.notReady(mode)
+
+	next := mode.armedState()
+	if a.armingDelay > 0 {
+		next = StateArming
+	}
+
+	entry := audit.Entry{Actor: actor.Name, Source: actor.Source, Action: audit.ActionArm, Mode: string(mode)}
+
+	// reject must be called with a.mux held, it unlocks it
+	reject := func(err error) error {
+		a.mux.Unlock()
+		a.audit(entry, err)
+
+		return err
+	}
+
+	a.mux.Lock()
+	entry.From = string(a.state)
+
+	if !canTransition(a.state, next) {
+		return reject(fmt.Errorf("arm %s: %w: %s -> %s", mode, ErrInvalidTransition, entry.From, next))
+	}
+
+	if len(notReady) > 0 && !force {
+		return reject(&NotReadyError{Devices: notReady})
+	}
+
+	bypassed := ""
+
+	if len(notReady) > 0 {
+		// bypass before arming, so the devices don't alarm in between
+		a.mux.Unlock()
+		a.bypassNotReady(notReady, actor)
+		a.mux.Lock()
+
+		bypassed = formatNotReady(notReady)
+
+		for _, d := range notReady {
+			entry.Bypassed = append(entry.Bypassed, d.Name)
+		}
+
+		// the state may have changed while devices were bypassed
+		entry.From = string(a.state)
+
+		if !canTransition(a.state, next) {
+			return reject(fmt.Errorf("arm %s: %w: %s -> %s", mode, ErrInvalidTransition, entry.From, next))
+		}
+	}
+
+	a.mode = mode
+	a.transition(next, actor, "arm requested")
+
+	if next == StateArming {
+		a.schedule(a.armingDelay, mode.armedState(), "arming delay passed", func() {
+			a.notifier.Notify(message.New(message.KeyArmed, message.Info, "alarm", "mode", string(mode), "bypassed", bypassed))
+		})
+	}
+
+	a.mux.Unlock()
+
+	entry.To = string(next)
+	a.audit(entry, nil)
+
+	if next == StateArming {
+		a.notifier.Notify(message.New(message.KeyArming, message.Info, "alarm",
+			"mode", string(mode), "delay", a.armingDelay.String(), "actor", actor.String(), "bypassed", bypassed))
 	} else {
-		a.l.Debug("alarm event received, but will be ignored: alarm disabled")
+		a.notifier.Notify(message.New(message.KeyArmed, message.Info, "alarm", "mode", string(mode), "actor", actor.String(), "bypassed", bypassed))
+	}
+
+	a.stateChanged()
+
+	return nil
+}
+
+// Disarm the alarm. The actor is recorded in the state history and the audit log.
+func (a *Alarmer) Disarm(actor Actor) error {
+	a.mux.Lock()
+
+	entry := audit.Entry{Actor: actor.Name, Source: actor.Source, Action: audit.ActionDisarm, From: string(a.state)}
+
+	if !canTransition(a.state, StateDisarmed) {
+		a.mux.Unlock()
+
+		err := fmt.Errorf("disarm: %w: %s -> %s", ErrInvalidTransition, entry.From, StateDisarmed)
+		a.audit(entry, err)
+
+		return err
+	}
+
+	a.transition(StateDisarmed, actor, "disarm requested")
+	a.mux.Unlock()
+
+	entry.To = string(StateDisarmed)
+	a.audit(entry, nil)
+
+	a.notifier.Notify(message.New(message.KeyDisarmed, message.Info, "alarm", "actor", actor.String()))
+	a.stateChanged()
+
+	return nil
+}
+
+// Alarm handles a device alarm. Depending on the state and mode it is ignored,
+// delayed by the entry delay or sent to the notifier right away.
+func (a *Alarmer) Alarm(device, reason string) {
+	a.mux.Lock()
+
+	if !a.state.armed() && a.state != StateTriggered {
+		a.l.Debug("alarm event received, but will be ignored", "device", device, "state", a.state)
+		a.mux.Unlock()
+		alarmsTotal.Inc(resultSuppressed)
+
+		return
+	}
+
+	if !a.monitored(device) {
+		a.l.Debug("alarm event received, but device is not monitored in current mode", "device", device, "mode", a.mode)
+		a.mux.Unlock()
+		alarmsTotal.Inc(resultSuppressed)
+
+		return
+	}
+
+	if a.state == StateTriggered {
+		a.mux.Unlock()
+		a.send(message.New(message.KeyAlarm, message.Critical, device, "reason", reason))
+
+		return
+	}
+
+	cause := fmt.Sprintf("%s: %s", device, reason)
+
+	if a.pendingDelay > 0 {
+		a.transition(StatePending, Actor{Name: device, Source: SourceDevice}, cause)
+		a.schedule(a.pendingDelay, StateTriggered, "pending delay passed", func() {
+			a.send(message.New(message.KeyAlarm, message.Critical, device, "reason", reason))
+		})
+		a.mux.Unlock()
+		a.stateChanged()
+
+		return
+	}
+
+	a.transition(StateTriggered, Actor{Name: device, Source: SourceDevice}, cause)
+	a.mux.Unlock()
+
+	a.send(message.New(message.KeyAlarm, message.Critical, device, "reason", reason))
+	a.stateChanged()
+}
+
+// Trigger manually raises the alarm if it is armed.
+func (a *Alarmer) Trigger(actor Actor) {
+	a.mux.Lock()
+
+	entry := audit.Entry{Actor: actor.Name, Source: actor.Source, Action: audit.ActionTrigger, From: string(a.state)}
+
+	if !canTransition(a.state, StateTriggered) {
+		a.l.Warn("manual trigger ignored", "actor", actor, "state", a.state)
+		a.mux.Unlock()
+		a.audit(entry, fmt.Errorf("trigger: %w: %s -> %s", ErrInvalidTransition, entry.From, StateTriggered))
+
+		return
+	}
+
+	a.transition(StateTriggered, actor, "triggered manually")
+	a.mux.Unlock()
+
+	entry.To = string(StateTriggered)
+	a.audit(entry, nil)
+
+	a.send(message.New(message.KeyAlarmManual, message.Critical, "alarm", "actor", actor.String()))
+	a.stateChanged()
+}
+
+// alerting returns true if alarms of the device are acted on: the alarm is armed or triggered
+// and the device is monitored in the current mode.
+func (a *Alarmer) alerting(device string) bool {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	return (a.state.armed() || a.state == StateTriggered) && a.monitored(device)
+}
+
+// monitored returns true if the device is monitored in the current mode. Must be called with a.mux held.
+func (a *Alarmer) monitored(device string) bool {
+	return a.monitoredIn(device, a.mode)
+}
+
+// monitoredIn returns true if the device is monitored in the mode. Must be called with a.mux held.
+func (a *Alarmer) monitoredIn(device string, mode Mode) bool {
+	modes, ok := a.deviceModes[device]
+	if !ok {
+		return true
+	}
+
+	return slices.Contains(modes, mode)
+}
+
+// send notifies about the alarm and records it.
+func (a *Alarmer) send(m message.Message) {
+	alarmsTotal.Inc(resultSent)
+	a.notifier.Notify(m)
+	a.recorder.Record(event.Event{Type: event.TypeAlarm, Device: m.Device, Message: m.String()})
+}
+
+// audit records the outcome of a state change request.
+func (a *Alarmer) audit(e audit.Entry, err error) {
+	e.Result = audit.ResultOK
+
+	if err != nil {
+		e.Result = audit.ResultRejected
+		e.Error = err.Error()
+	}
+
+	a.auditor.Audit(e)
+}
+
+// transition changes the state and cancels pending timers. Must be called with a.mux held.
+func (a *Alarmer) transition(to State, actor Actor, reason string) {
+	if a.timer != nil {
+		a.timer.Stop()
+		a.timer = nil
+	}
+
+	t := Transition{From: a.state, To: to, Actor: actor.Name, Source: actor.Source, Reason: reason, Time: time.Now()}
+
+	a.seq++
+	a.state = to
+	a.history = append(a.history, t)
+
+	if len(a.history) > historySize {
+		a.history = a.history[len(a.history)-historySize:]
+	}
+
+	a.recorder.Record(event.Event{
+		Time:    t.Time,
+		Type:    event.TypeStateChange,
+		Actor:   actor.Name,
+		Source:  actor.Source,
+		Message: reason,
+		State:   string(to),
+	})
+	a.l.Info("alarm state changed", "from", t.From, "to", t.To, "actor", actor.Name, "source", actor.Source, "reason", reason)
+}
+
+// schedule moves the alarm to the next state after the delay, unless another transition happens first.
+// Must be called with a.mux held.
+func (a *Alarmer) schedule(delay time.Duration, next State, reason string, after func()) {
+	seq := a.seq
+
+	a.timer = time.AfterFunc(delay, func() {
+		a.mux.Lock()
+
+		if a.seq != seq {
+			a.mux.Unlock()
+			return
+		}
+
+		a.timer = nil
+		a.transition(next, Actor{Source: SourceSystem}, reason)
+		a.mux.Unlock()
+
+		after()
+		a.stateChanged()
+	})
+}
+
+func (a *Alarmer) stateChanged() {
+	a.obsMux.Lock()
+	observers := a.observers
+	a.obsMux.Unlock()
+
+	for _, o := range observers {
+		o.AlarmStateChanged()
 	}
 }
@@ -0,0 +1,214 @@
+package alarm_test
+
+import (
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	"github.com/SuddenGunter/hsd/audit"
+	"github.com/SuddenGunter/hsd/event"
+	"github.com/SuddenGunter/hsd/notify/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeNotifier struct {
+	mux  sync.Mutex
+	msgs []string
+}
+
+func (n *fakeNotifier) Notify(m message.Message) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	n.msgs = append(n.msgs, m.Device+": "+m.String())
+}
+
+func (n *fakeNotifier) messages() []string {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	return append([]string(nil), n.msgs...)
+}
+
+type nopRecorder struct{}
+
+func (nopRecorder) Record(event.Event) {}
+
+type fakeAuditor struct {
+	mux     sync.Mutex
+	entries []audit.Entry
+}
+
+func (a *fakeAuditor) Audit(e audit.Entry) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	a.entries = append(a.entries, e)
+}
+
+var alice = alarm.Actor{Name: "alice", Source: alarm.SourceAPI}
+
+func newAlarmer(t *testing.T, opts alarm.Options) (*alarm.Alarmer, *fakeNotifier) {
+	t.Helper()
+
+	a, n, _ := newAudited(t, opts)
+
+	return a, n
+}
+
+func newAudited(t *testing.T, opts alarm.Options) (*alarm.Alarmer, *fakeNotifier, *fakeAuditor) {
+	t.Helper()
+
+	n := &fakeNotifier{}
+	au := &fakeAuditor{}
+
+	return alarm.New(n, nopRecorder{}, au, opts, slog.New(slog.NewTextHandler(io.Discard, nil))), n, au
+}
+
+func TestAlarmer_StartsArmedAway(t *testing.T) {
+	t.Parallel()
+
+	a, _ := newAlarmer(t, alarm.Options{})
+
+	assert.Equal(t, alarm.StateArmedAway, a.State())
+	assert.Equal(t, alarm.ModeAway, a.Mode())
+	assert.True(t, a.Enabled())
+}
+
+func TestAlarmer_AlarmTriggersImmediatelyWithoutPendingDelay(t *testing.T) {
+	t.Parallel()
+
+	a, n := newAlarmer(t, alarm.Options{})
+
+	a.Alarm("door1", "opened")
+
+	assert.Equal(t, alarm.StateTriggered, a.State())
+	assert.Equal(t, []string{"door1: opened"}, n.messages())
+}
+
+func TestAlarmer_DisarmedIgnoresAlarms(t *testing.T) {
+	t.Parallel()
+
+	a, n := newAlarmer(t, alarm.Options{})
+
+	require.NoError(t, a.Disarm(alice))
+	a.Alarm("door1", "opened")
+
+	assert.Equal(t, alarm.StateDisarmed, a.State())
+	assert.Equal(t, []string{"alarm: disarmed by alice via api"}, n.messages())
+}
+
+func TestAlarmer_InvalidTransition(t *testing.T) {
+	t.Parallel()
+
+	a, _ := newAlarmer(t, alarm.Options{})
+
+	require.NoError(t, a.Disarm(alice))
+
+	err := a.Disarm(alice)
+
+	require.ErrorIs(t, err, alarm.ErrInvalidTransition)
+	assert.Equal(t, alarm.StateDisarmed, a.State())
+}
+
+func TestAlarmer_AuditsStateChangeRequests(t *testing.T) {
+	t.Parallel()
+
+	a, _, au := newAudited(t, alarm.Options{})
+	ha := alarm.Actor{Source: alarm.SourceHomeAssistant}
+
+	require.NoError(t, a.Disarm(alice))
+	require.Error(t, a.Disarm(ha))
+	require.NoError(t, a.Arm(alarm.ModeHome, ha))
+
+	require.Len(t, au.entries, 3)
+
+	assert.Equal(t, "alice", au.entries[0].Actor)
+	assert.Equal(t, alarm.SourceAPI, au.entries[0].Source)
+	assert.Equal(t, audit.ActionDisarm, au.entries[0].Action)
+	assert.Equal(t, string(alarm.StateArmedAway), au.entries[0].From)
+	assert.Equal(t, string(alarm.StateDisarmed), au.entries[0].To)
+	assert.Equal(t, audit.ResultOK, au.entries[0].Result)
+
+	assert.Equal(t, alarm.SourceHomeAssistant, au.entries[1].Source)
+	assert.Equal(t, audit.ResultRejected, au.entries[1].Result)
+	assert.NotEmpty(t, au.entries[1].Error)
+
+	assert.Equal(t, audit.ActionArm, au.entries[2].Action)
+	assert.Equal(t, string(alarm.ModeHome), au.entries[2].Mode)
+	assert.Equal(t, string(alarm.StateArmedHome), au.entries[2].To)
+
+	h := a.History()
+	assert.Equal(t, "alice", h[1].Actor)
+	assert.Equal(t, alarm.SourceAPI, h[1].Source)
+}
+
+func TestAlarmer_DeviceModesSelectMonitoredDevices(t *testing.T) {
+	t.Parallel()
+
+	a, n := newAlarmer(t, alarm.Options{DeviceModes: map[string][]alarm.Mode{"window1": {alarm.ModeAway}}})
+
+	require.NoError(t, a.Arm(alarm.ModeNight, alice))
+	a.Alarm("window1", "opened")
+
+	assert.Equal(t, alarm.StateArmedNight, a.State())
+
+	a.Alarm("door1", "opened")
+
+	assert.Equal(t, alarm.StateTriggered, a.State())
+	assert.Equal(t, []string{"alarm: armed (night) by alice via api", "door1: opened"}, n.messages())
+}
+
+func TestAlarmer_ArmingDelay(t *testing.T) {
+	t.Parallel()
+
+	a, _ := newAlarmer(t, alarm.Options{ArmingDelay: 20 * time.Millisecond})
+
+	require.NoError(t, a.Disarm(alice))
+	require.NoError(t, a.Arm(alarm.ModeHome, alice))
+
+	assert.Equal(t, alarm.StateArming, a.State())
+
+	a.Alarm("door1", "opened")
+
+	assert.Equal(t, alarm.StateArming, a.State())
+	assert.Eventually(t, func() bool { return a.State() == alarm.StateArmedHome }, time.Second, 5*time.Millisecond)
+}
+
+func TestAlarmer_DisarmDuringPendingCancelsAlarm(t *testing.T) {
+	t.Parallel()
+
+	a, n := newAlarmer(t, alarm.Options{PendingDelay: 20 * time.Millisecond})
+
+	a.Alarm("door1", "opened")
+
+	assert.Equal(t, alarm.StatePending, a.State())
+
+	require.NoError(t, a.Disarm(alice))
+	time.Sleep(50 * time.Millisecond)
+
+	assert.Equal(t, alarm.StateDisarmed, a.State())
+	assert.Equal(t, []string{"alarm: disarmed by alice via api"}, n.messages())
+}
+
+func TestAlarmer_PendingDelayTriggers(t *testing.T) {
+	t.Parallel()
+
+	a, n := newAlarmer(t, alarm.Options{PendingDelay: 20 * time.Millisecond})
+
+	a.Alarm("door1", "opened")
+
+	assert.Eventually(t, func() bool { return len(n.messages()) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, alarm.StateTriggered, a.State())
+	assert.Equal(t, []string{"door1: opened"}, n.messages())
+
+	h := a.History()
+	require.Len(t, h, 3)
+	assert.Equal(t, alarm.StateArmedAway, h[1].From)
+	assert.Equal(t, alarm.StatePending, h[1].To)
+	assert.Equal(t, alarm.StateTriggered, h[2].To)
+}
@@ -0,0 +1,79 @@
+// Package alarmtest provides test doubles for the alarm package.
+package alarmtest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/SuddenGunter/hsd/alarm"
+)
+
+// Clock is an alarm.Clock that fires scheduled functions when the test advances it.
+type Clock struct {
+	mux    sync.Mutex
+	now    time.Duration
+	timers []*timer
+}
+
+type timer struct {
+	c       *Clock
+	at      time.Duration
+	f       func()
+	stopped bool
+}
+
+// Stop cancels the timer, it returns false if it already fired or was stopped.
+func (t *timer) Stop() bool {
+	t.c.mux.Lock()
+	defer t.c.mux.Unlock()
+
+	stopped := t.stopped
+	t.stopped = true
+
+	return !stopped
+}
+
+// AfterFunc schedules f to be called once the clock is advanced by d.
+func (c *Clock) AfterFunc(d time.Duration, f func()) alarm.Timer {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	t := &timer{c: c, at: c.now + d, f: f}
+	c.timers = append(c.timers, t)
+
+	return t
+}
+
+// Scheduled returns the number of functions waiting to be called.
+func (c *Clock) Scheduled() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return len(c.timers)
+}
+
+// Advance moves the clock forward and calls due functions in the calling goroutine.
+func (c *Clock) Advance(d time.Duration) {
+	c.mux.Lock()
+	c.now += d
+
+	var due []*timer
+
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at <= c.now {
+			due = append(due, t)
+		} else {
+			pending = append(pending, t)
+		}
+	}
+
+	c.timers = pending
+	c.mux.Unlock()
+
+	for _, t := range due {
+		if t.Stop() {
+			t.f()
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package alarm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SuddenGunter/hsd/event"
+	"github.com/SuddenGunter/hsd/notify/message"
+)
+
+// Bypass excludes a device from alarms, e.g. a faulty sensor or a window left open deliberately.
+type Bypass struct {
+	// Until is when the bypass expires. Nil means it lasts until the alarm is disarmed.
+	Until  *time.Time `json:"until"`
+	Since  time.Time  `json:"since"`
+	Actor  string     `json:"actor,omitempty"`
+	Source string     `json:"source"`
+	Reason string     `json:"reason,omitempty"`
+}
+
+func (b Bypass) String() string {
+	s := "until disarmed"
+	if b.Until != nil {
+		s = "for " + b.Until.Sub(b.Since).Round(time.Second).String()
+	}
+
+	s += " by " + Actor{Name: b.Actor, Source: b.Source}.String()
+
+	if b.Reason != "" {
+		s += ": " + b.Reason
+	}
+
+	return s
+}
+
+// Bypass excludes the device from alarms until the bypass expires or is ended.
+// A new bypass replaces the current one.
+func (d *Device) Bypass(ctx context.Context, b Bypass) (DeviceStatus, error) {
+	var s DeviceStatus
+
+	err := d.do(ctx, func() {
+		d.setBypass(b)
+		s = d.snapshot()
+	})
+
+	return s, err
+}
+
+// EndBypass reinstates the device if it is bypassed.
+func (d *Device) EndBypass(ctx context.Context, actor Actor) (DeviceStatus, error) {
+	var s DeviceStatus
+
+	err := d.do(ctx, func() {
+		d.endBypass(message.New(message.KeyBypassEnded, message.Info, d.name, "actor", actor.String()))
+		s = d.snapshot()
+	})
+
+	return s, err
+}
+
+// endDisarmBypass reinstates the device if it is bypassed until the alarm is disarmed.
+func (d *Device) endDisarmBypass(ctx context.Context) error {
+	return d.do(ctx, func() {
+		if d.bypass != nil && d.bypass.Until == nil {
+			d.endBypass(message.New(message.KeyBypassDisarmed, message.Info, d.name))
+		}
+	})
+}
+
+// setBypass must be called from the device loop.
+func (d *Device) setBypass(b Bypass) {
+	if d.bypassTimer != nil {
+		d.bypassTimer.Stop()
+		d.bypassTimer = nil
+	}
+
+	b.Since = time.Now()
+	d.bypass = &b
+
+	if b.Until != nil {
+		d.bypassTimer = time.NewTimer(time.Until(*b.Until))
+	}
+
+	duration := ""
+	if b.Until != nil {
+		duration = b.Until.Sub(b.Since).Round(time.Second).String()
+	}
+
+	m := message.New(message.KeyBypassed, message.Warning, d.name,
+		"duration", duration, "actor", Actor{Name: b.Actor, Source: b.Source}.String(), "reason", b.Reason)
+
+	d.l.Info("device bypassed", "device", d.name, "until", b.Until, "actor", b.Actor, "source", b.Source, "reason", b.Reason)
+	d.recorder.Record(event.Event{Type: event.TypeBypass, Device: d.name, Actor: b.Actor, Source: b.Source, Message: m.String()})
+	d.notifier.Notify(m)
+}
+
+// endBypass must be called from the device loop. The message tells why the bypass ended.
+func (d *Device) endBypass(m message.Message) {
+	if d.bypass == nil {
+		return
+	}
+
+	if d.bypassTimer != nil {
+		d.bypassTimer.Stop()
+		d.bypassTimer = nil
+	}
+
+	d.bypass = nil
+
+	msg := m.String()
+
+	d.l.Info("device bypass ended", "device", d.name, "reason", msg)
+	d.recorder.Record(event.Event{Type: event.TypeBypass, Device: d.name, Source: SourceSystem, Message: msg})
+	d.notifier.Notify(m)
+
+	// alarm right away if the device is still open or unavailable
+	if d.lastUpdated != 0 {
+		d.evalAlarm()
+	}
+}
+
+// bypassExpired returns the channel of the bypass timer, or nil if there is none.
+func (d *Device) bypassExpired() <-chan time.Time {
+	if d.bypassTimer == nil {
+		return nil
+	}
+
+	return d.bypassTimer.C
+}
+
+// Bypass excludes the device from alarms, see Device.Bypass.
+func (m *DeviceMessenger) Bypass(ctx context.Context, device string, b Bypass) (DeviceStatus, error) {
+	d, ok := m.device(device)
+	if !ok {
+		return DeviceStatus{}, fmt.Errorf("device %s: %w", device, ErrDeviceNotFound)
+	}
+
+	return d.Bypass(ctx, b)
+}
+
+// EndBypass reinstates the device, see Device.EndBypass.
+func (m *DeviceMessenger) EndBypass(ctx context.Context, device string, actor Actor) (DeviceStatus, error) {
+	d, ok := m.device(device)
+	if !ok {
+		return DeviceStatus{}, fmt.Errorf("device %s: %w", device, ErrDeviceNotFound)
+	}
+
+	return d.EndBypass(ctx, actor)
+}
+
+// AlarmStateChanged ends bypasses that last until the alarm is disarmed. It implements the Alarmer observer.
+func (m *DeviceMessenger) AlarmStateChanged() {
+	m.mux.RLock()
+	state := m.alarmState
+	m.mux.RUnlock()
+
+	if state == nil || state() != StateDisarmed {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, d := range m.all() {
+		if err := d.endDisarmBypass(ctx); err != nil && !errors.Is(err, ErrDeviceClosed) {
+			m.l.Error("failed to end device bypass", "device", d.name, "err", err)
+		}
+	}
+}
+
+// ObserveAlarm registers the messenger with the alarmer, so bypasses until disarm end when it is disarmed.
+func (m *DeviceMessenger) ObserveAlarm(a *Alarmer) {
+	m.mux.Lock()
+	m.alarmState = a.State
+	m.alerting = a.alerting
+	m.mux.Unlock()
+
+	a.Observe(m)
+}
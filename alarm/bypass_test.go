@@ -0,0 +1,104 @@
+package alarm_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBypassMessenger(t *testing.T) (*alarm.Alarmer, *alarm.DeviceMessenger, *fakeNotifier) {
+	t.Helper()
+
+	a, n := newAlarmer(t, alarm.Options{})
+	m := alarm.NewDeviceMessenger([]string{"door1"}, a, n, nopRecorder{}, alarm.MessengerOptions{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	m.ObserveAlarm(a)
+	m.Listen()
+
+	t.Cleanup(m.Close)
+
+	return a, m, n
+}
+
+func TestDeviceMessenger_BypassExpires(t *testing.T) {
+	t.Parallel()
+
+	_, m, n := newBypassMessenger(t)
+	ctx := context.Background()
+
+	until := time.Now().Add(100 * time.Millisecond)
+	s, err := m.Bypass(ctx, "door1", alarm.Bypass{Until: &until, Actor: "alice", Source: alarm.SourceAPI, Reason: "window left open"})
+	require.NoError(t, err)
+	require.NotNil(t, s.Bypass)
+	assert.Equal(t, "alice", s.Bypass.Actor)
+
+	m.SetData(ctx, "door1", alarm.SensorData{Opened: true})
+
+	s, err = m.Status(ctx, "door1")
+	require.NoError(t, err)
+	require.NotNil(t, s.LastAlarm)
+	assert.NotContains(t, n.messages(), "door1: opened")
+
+	// the door is still open when the bypass expires, so the alarm goes off
+	require.Eventually(t, func() bool {
+		msgs := n.messages()
+		return len(msgs) == 3 && msgs[2] == "door1: opened"
+	}, time.Second, 10*time.Millisecond)
+
+	msgs := n.messages()
+	assert.Regexp(t, `^door1: bypassed for \S+ by alice via api: window left open$`, msgs[0])
+	assert.Equal(t, "door1: bypass expired, monitoring resumed", msgs[1])
+
+	s, err = m.Status(ctx, "door1")
+	require.NoError(t, err)
+	assert.Nil(t, s.Bypass)
+}
+
+func TestDeviceMessenger_BypassUntilDisarm(t *testing.T) {
+	t.Parallel()
+
+	a, m, n := newBypassMessenger(t)
+	ctx := context.Background()
+
+	_, err := m.Bypass(ctx, "door1", alarm.Bypass{Source: alarm.SourceAPI})
+	require.NoError(t, err)
+
+	m.SetData(ctx, "door1", alarm.SensorData{Opened: true})
+	require.NoError(t, a.Arm(alarm.ModeNight, alice))
+
+	s, err := m.Status(ctx, "door1")
+	require.NoError(t, err)
+	require.NotNil(t, s.Bypass, "re-arming keeps the bypass")
+	assert.Nil(t, s.Bypass.Until)
+
+	require.NoError(t, a.Disarm(alice))
+
+	s, err = m.Status(ctx, "door1")
+	require.NoError(t, err)
+	assert.Nil(t, s.Bypass)
+	assert.Contains(t, n.messages(), "door1: bypass alarm disarmed, monitoring resumed")
+	assert.NotContains(t, n.messages(), "door1: opened")
+}
+
+func TestDeviceMessenger_EndBypass(t *testing.T) {
+	t.Parallel()
+
+	_, m, n := newBypassMessenger(t)
+	ctx := context.Background()
+
+	_, err := m.Bypass(ctx, "door1", alarm.Bypass{Source: alarm.SourceAPI})
+	require.NoError(t, err)
+
+	s, err := m.EndBypass(ctx, "door1", alice)
+	require.NoError(t, err)
+	assert.Nil(t, s.Bypass)
+	assert.Contains(t, n.messages(), "door1: bypass ended by alice via api, monitoring resumed")
+
+	_, err = m.EndBypass(ctx, "door2", alice)
+	require.ErrorIs(t, err, alarm.ErrDeviceNotFound)
+}
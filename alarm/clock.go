@@ -0,0 +1,21 @@
+package alarm
+
+import "time"
+
+// Clock schedules functions. It is replaced in tests to control time.
+type Clock interface {
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a scheduled function that can be cancelled.
+type Timer interface {
+	Stop() bool
+}
+
+// SystemClock is the Clock backed by the time package.
+type SystemClock struct{}
+
+// AfterFunc calls f in its own goroutine after d.
+func (SystemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
@@ -2,26 +2,58 @@ package alarm
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"strconv"
 	"time"
+
+	"github.com/SuddenGunter/hsd/event"
+	"github.com/SuddenGunter/hsd/notify/message"
 )
 
+// ErrDeviceClosed is returned when querying a device that was closed.
+var ErrDeviceClosed = errors.New("device closed")
+
 type alarmer interface {
 	Alarm(device, message string)
 }
 
+type deviceObserver interface {
+	DeviceStateChanged(device string, available, opened bool)
+}
+
+// DeviceConfig describes a monitored device.
+type DeviceConfig struct {
+	Name string `json:"name"`
+	// Modes the device is monitored in, all modes if empty.
+	Modes []Mode `json:"modes,omitempty"`
+	// Label and Location are shown in notifications instead of the zigbee2mqtt name.
+	Label    string `json:"label,omitempty"`
+	Location string `json:"location,omitempty"`
+}
+
 // Device that can be alarmed. Processes state updates and alarms if necessary.
 // For not the only type of a supported device is a door sensor.
 type Device struct {
-	alarmer alarmer
+	alarmer  alarmer
+	notifier notifier
+	recorder recorder
+	observer deviceObserver
 
 	name        string
 	available   bool
 	opened      bool
+	battery     int
+	linkQuality int
 	lastUpdated int64
+	lastAlarm   *DeviceAlarm
+	bypass      *Bypass
+	bypassTimer *time.Timer
 
 	stateUpdate chan stateUpdateMsg
+	status      chan chan DeviceStatus
+	exec        chan func()
 	close       chan struct{}
 
 	l *slog.Logger
@@ -29,18 +61,49 @@ type Device struct {
 
 type stateUpdateMsg struct {
 	availability *bool
-	opened       *bool
+	data         *SensorData
 }
 
-// NewDevice returns a new Device.
-func NewDevice(name string, alarmer alarmer, l *slog.Logger) *Device {
+// SensorData is a state update reported by the device itself.
+type SensorData struct {
+	Opened      bool
+	Battery     int
+	LinkQuality int
+}
+
+// DeviceAlarm is the last alarm raised by the device.
+// The alarm may still have been ignored by Alarmer, e.g. if it was disarmed.
+type DeviceAlarm struct {
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// DeviceStatus is a snapshot of the device state.
+type DeviceStatus struct {
+	Name        string       `json:"name"`
+	Available   bool         `json:"available"`
+	Opened      bool         `json:"opened"`
+	Battery     int          `json:"battery"`
+	LinkQuality int          `json:"linkQuality"`
+	LastUpdated *time.Time   `json:"lastUpdated"`
+	LastAlarm   *DeviceAlarm `json:"lastAlarm"`
+	// Bypass is set while the device is excluded from alarms.
+	Bypass *Bypass `json:"bypass"`
+}
+
+// NewDevice returns a new Device. The notifier is told when the device is bypassed and reinstated.
+func NewDevice(name string, alarmer alarmer, notifier notifier, recorder recorder, l *slog.Logger) *Device {
 	return &Device{
-		name:    name,
-		alarmer: alarmer,
+		name:     name,
+		alarmer:  alarmer,
+		notifier: notifier,
+		recorder: recorder,
 		// we assume it's available unless we hear otherwise
 		available:   true,
 		opened:      false,
 		stateUpdate: make(chan stateUpdateMsg),
+		status:      make(chan chan DeviceStatus),
+		exec:        make(chan func()),
 		close:       make(chan struct{}),
 		l:           l,
 	}
@@ -59,38 +122,97 @@ func (d *Device) SetAvailability(ctx context.Context, available bool) {
 	}
 }
 
-// SetOpened sets the opened state of the device.
-func (d *Device) SetOpened(ctx context.Context, opened bool) {
+// SetData sets the state reported by the device.
+func (d *Device) SetData(ctx context.Context, data SensorData) {
 	select {
 	case <-ctx.Done():
-		d.l.Error("device state update timeout", "device", d.name, "operation", "SetOpened", "reason", ctx.Err())
+		d.l.Error("device state update timeout", "device", d.name, "operation", "SetData", "reason", ctx.Err())
 		return
-	case d.stateUpdate <- stateUpdateMsg{opened: &opened}:
+	case d.stateUpdate <- stateUpdateMsg{data: &data}:
 		return
 	case <-d.close:
 		return
 	}
 }
 
+// Status returns a snapshot of the device state.
+func (d *Device) Status(ctx context.Context) (DeviceStatus, error) {
+	resp := make(chan DeviceStatus, 1)
+
+	select {
+	case <-ctx.Done():
+		return DeviceStatus{}, fmt.Errorf("device %s: status: %w", d.name, ctx.Err())
+	case <-d.close:
+		return DeviceStatus{}, fmt.Errorf("device %s: status: %w", d.name, ErrDeviceClosed)
+	case d.status <- resp:
+		return <-resp, nil
+	}
+}
+
+// do runs fn in the device loop and waits for it to finish.
+func (d *Device) do(ctx context.Context, fn func()) error {
+	done := make(chan struct{})
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("device %s: %w", d.name, ctx.Err())
+	case <-d.close:
+		return fmt.Errorf("device %s: %w", d.name, ErrDeviceClosed)
+	case d.exec <- func() { fn(); close(done) }:
+		<-done
+		return nil
+	}
+}
+
 func (d *Device) loop() {
+	defer func() {
+		if d.bypassTimer != nil {
+			d.bypassTimer.Stop()
+		}
+	}()
+
 	for {
 		select {
 		case <-d.close:
 			return
 
+		case resp := <-d.status:
+			resp <- d.snapshot()
+
+		case fn := <-d.exec:
+			fn()
+
+		case <-d.bypassExpired():
+			d.bypassTimer = nil
+			d.endBypass(message.New(message.KeyBypassExpired, message.Info, d.name))
+
 		case msg := <-d.stateUpdate:
-			d.l.Info("device state update received", "device", d.name, "availability", ptr(msg.availability), "opened", ptr(msg.opened))
+			d.l.Info("device state update received", "device", d.name, "availability", ptr(msg.availability), "data", msg.data)
 
 			if msg.availability != nil {
+				if *msg.availability != d.available {
+					d.recorder.Record(event.Event{Type: event.TypeAvailability, Device: d.name, Available: msg.availability})
+				}
+
 				d.available = *msg.availability
 			}
 
-			if msg.opened != nil {
-				d.opened = *msg.opened
+			if msg.data != nil {
+				if msg.data.Opened != d.opened || d.lastUpdated == 0 {
+					d.recorder.Record(event.Event{Type: event.TypeDeviceUpdate, Device: d.name, Opened: &msg.data.Opened})
+				}
+
+				d.opened = msg.data.Opened
+				d.battery = msg.data.Battery
+				d.linkQuality = msg.data.LinkQuality
 			}
 
 			d.lastUpdated = time.Now().Unix()
 
+			if d.observer != nil {
+				d.observer.DeviceStateChanged(d.name, d.available, d.opened)
+			}
+
 			d.evalAlarm()
 		}
 	}
@@ -98,19 +220,59 @@ func (d *Device) loop() {
 
 func (d *Device) evalAlarm() {
 	if d.opened {
-		d.alarmer.Alarm(d.name, "opened")
+		d.alarm(MessageOpened)
 		return
 	}
 
 	if !d.available {
-		d.alarmer.Alarm(d.name, "unavailable")
+		d.alarm(MessageUnavailable)
 		return
 	}
 
 	if d.lastUpdated < time.Now().Add(-time.Hour*26).Unix() {
-		d.alarmer.Alarm(d.name, "no messages received for a long time")
+		d.alarm(MessageStale)
+		return
+	}
+}
+
+func (d *Device) alarm(reason string) {
+	d.lastAlarm = &DeviceAlarm{Message: reason, Time: time.Now()}
+
+	if d.bypass != nil {
+		d.l.Info("alarm event received, but device is bypassed", "device", d.name, "message", reason)
+		alarmsTotal.Inc(resultBypassed)
+
 		return
 	}
+
+	d.alarmer.Alarm(d.name, reason)
+}
+
+func (d *Device) snapshot() DeviceStatus {
+	s := DeviceStatus{
+		Name:        d.name,
+		Available:   d.available,
+		Opened:      d.opened,
+		Battery:     d.battery,
+		LinkQuality: d.linkQuality,
+	}
+
+	if d.lastUpdated != 0 {
+		t := time.Unix(d.lastUpdated, 0)
+		s.LastUpdated = &t
+	}
+
+	if d.lastAlarm != nil {
+		a := *d.lastAlarm
+		s.LastAlarm = &a
+	}
+
+	if d.bypass != nil {
+		b := *d.bypass
+		s.Bypass = &b
+	}
+
+	return s
 }
 
 func ptr(b *bool) string {
@@ -2,55 +2,280 @@ package alarm
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 )
 
+// ErrDeviceNotFound is returned when the device is not monitored.
+var ErrDeviceNotFound = errors.New("device not found")
+
 // DeviceMessenger is a collection of devices that can be alarmed.
 // Handles creation of devices, their state updates and lifecycle.
+// Devices can be added and removed at any time.
 type DeviceMessenger struct {
-	devices map[string]*Device
+	mux       *sync.RWMutex
+	devices   map[string]*Device
+	listening bool
+
+	alarmer    alarmer
+	notifier   notifier
+	recorder   recorder
+	observer   deviceObserver
+	alarmState func() State
+	alerting   func(device string) bool
+
+	throttle          ThrottlePolicy
+	throttleOverrides map[string]ThrottlePolicy
+	clock             Clock
 
 	l *slog.Logger
 }
 
+// MessengerOptions configure the DeviceMessenger.
+type MessengerOptions struct {
+	// Throttle limits how often each device alarms. The zero value disables throttling.
+	Throttle ThrottlePolicy
+	// ThrottleOverrides replace Throttle for a device name or an alarm kind, device names take precedence.
+	ThrottleOverrides map[string]ThrottlePolicy
+	// Clock schedules the end of throttle windows, SystemClock if nil.
+	Clock Clock
+}
+
 // NewDeviceMessenger returns a new DeviceMessenger.
-func NewDeviceMessenger(devices []string, alarmer alarmer, l *slog.Logger) *DeviceMessenger {
-	d := make(map[string]*Device)
+func NewDeviceMessenger(devices []string, alarmer alarmer, notifier notifier, recorder recorder, opts MessengerOptions, l *slog.Logger) *DeviceMessenger {
+	clock := opts.Clock
+	if clock == nil {
+		clock = SystemClock{}
+	}
+
+	m := &DeviceMessenger{
+		mux:               &sync.RWMutex{},
+		devices:           make(map[string]*Device),
+		alarmer:           alarmer,
+		notifier:          notifier,
+		recorder:          recorder,
+		throttle:          opts.Throttle,
+		throttleOverrides: maps.Clone(opts.ThrottleOverrides),
+		clock:             clock,
+		l:                 l,
+	}
+
 	for _, device := range devices {
-		d[device] = NewDevice(device, newDebouncer(alarmer, l), l)
+		m.devices[device] = m.newDevice(device)
 	}
 
-	return &DeviceMessenger{devices: d, l: l}
+	return m
 }
 
 // SetAvailability sets the availability of the device.
 func (m *DeviceMessenger) SetAvailability(ctx context.Context, device string, available bool) {
-	if d, ok := m.devices[device]; ok {
+	if d, ok := m.device(device); ok {
 		d.SetAvailability(ctx, available)
 	} else {
 		m.l.Error("device not found", "device", device, "operation", "SetAvailability")
 	}
 }
 
-// SetOpened sets the opened state of the device.
-func (m *DeviceMessenger) SetOpened(ctx context.Context, device string, opened bool) {
-	if d, ok := m.devices[device]; ok {
-		d.SetOpened(ctx, opened)
+// SetData sets the state reported by the device.
+func (m *DeviceMessenger) SetData(ctx context.Context, device string, data SensorData) {
+	if d, ok := m.device(device); ok {
+		d.SetData(ctx, data)
 	} else {
-		m.l.Error("device not found", "device", device, "operation", "SetOpened")
+		m.l.Error("device not found", "device", device, "operation", "SetData")
+	}
+}
+
+// Status returns a snapshot of the device state.
+func (m *DeviceMessenger) Status(ctx context.Context, device string) (DeviceStatus, error) {
+	d, ok := m.device(device)
+	if !ok {
+		return DeviceStatus{}, fmt.Errorf("device %s: %w", device, ErrDeviceNotFound)
+	}
+
+	return d.Status(ctx)
+}
+
+// Check returns an error if any device goroutine does not respond in time.
+func (m *DeviceMessenger) Check(ctx context.Context) error {
+	devices := m.all()
+
+	var errs []error
+
+	for _, d := range devices {
+		_, err := d.Status(ctx)
+		if err != nil && !errors.Is(err, ErrDeviceClosed) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Statuses returns snapshots of all devices sorted by name.
+func (m *DeviceMessenger) Statuses(ctx context.Context) ([]DeviceStatus, error) {
+	devices := m.all()
+
+	statuses := make([]DeviceStatus, 0, len(devices))
+
+	for _, d := range devices {
+		s, err := d.Status(ctx)
+		if errors.Is(err, ErrDeviceClosed) {
+			// removed while we were collecting statuses
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		statuses = append(statuses, s)
+	}
+
+	slices.SortFunc(statuses, func(a, b DeviceStatus) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return statuses, nil
+}
+
+// AddDevice starts monitoring a new device. Adding a device that is already monitored is a no-op.
+func (m *DeviceMessenger) AddDevice(name string) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if _, ok := m.devices[name]; ok {
+		return
+	}
+
+	d := m.newDevice(name)
+	m.devices[name] = d
+
+	if m.listening {
+		go d.loop()
+	}
+
+	m.l.Info("device added", "device", name)
+}
+
+// RemoveDevice stops monitoring the device.
+func (m *DeviceMessenger) RemoveDevice(name string) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	d, ok := m.devices[name]
+	if !ok {
+		return
+	}
+
+	delete(m.devices, name)
+	close(d.close)
+
+	m.l.Info("device removed", "device", name)
+}
+
+// Observe registers an observer that is called after every device state update.
+// Must be called before Listen.
+func (m *DeviceMessenger) Observe(o deviceObserver) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.observer = o
+
+	for _, d := range m.devices {
+		d.observer = o
 	}
 }
 
 // Close closes all devices.
 func (m *DeviceMessenger) Close() {
-	for _, d := range m.devices {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	for name, d := range m.devices {
 		close(d.close)
+		delete(m.devices, name)
 	}
 }
 
 // Listen starts listening for device state updates.
 func (m *DeviceMessenger) Listen() {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.listening = true
+
 	for _, d := range m.devices {
 		go d.loop()
 	}
 }
+
+func (m *DeviceMessenger) all() []*Device {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	return slices.Collect(maps.Values(m.devices))
+}
+
+func (m *DeviceMessenger) device(name string) (*Device, bool) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	d, ok := m.devices[name]
+
+	return d, ok
+}
+
+// newDevice must be called with m.mux held.
+func (m *DeviceMessenger) newDevice(name string) *Device {
+	policy := func(message string) ThrottlePolicy {
+		if p, ok := m.throttleOverrides[name]; ok {
+			return p
+		}
+
+		if p, ok := m.throttleOverrides[messageKinds[message]]; ok {
+			return p
+		}
+
+		return m.throttle
+	}
+
+	var d *Device
+
+	t := newThrottler(m.alarmer, m.notifier, m.clock, policy, func() bool { return m.alerts(d) }, m.l)
+	d = NewDevice(name, t, m.notifier, m.recorder, m.l)
+	d.observer = m.observer
+
+	return d
+}
+
+// alerts returns true if alarms of the device are still acted on: it is monitored, not bypassed
+// and the alarm is armed or triggered.
+func (m *DeviceMessenger) alerts(d *Device) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s, err := d.Status(ctx)
+	if err != nil {
+		if !errors.Is(err, ErrDeviceClosed) {
+			m.l.Error("failed to get device status", "device", d.name, "err", err)
+		}
+
+		return false
+	}
+
+	if s.Bypass != nil {
+		return false
+	}
+
+	m.mux.RLock()
+	alerting := m.alerting
+	m.mux.RUnlock()
+
+	return alerting == nil || alerting(d.name)
+}
@@ -0,0 +1,48 @@
+package alarm_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type nopAlarmer struct{}
+
+func (nopAlarmer) Alarm(string, string) {}
+
+func TestDeviceMessenger_Status(t *testing.T) {
+	t.Parallel()
+
+	m := alarm.NewDeviceMessenger([]string{"door2", "door1"}, nopAlarmer{}, &fakeNotifier{}, nopRecorder{}, alarm.MessengerOptions{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	m.Listen()
+
+	defer m.Close()
+
+	ctx := context.Background()
+	m.SetData(ctx, "door1", alarm.SensorData{Opened: true, Battery: 90, LinkQuality: 120})
+	m.SetAvailability(ctx, "door2", false)
+
+	s, err := m.Status(ctx, "door1")
+	require.NoError(t, err)
+	assert.True(t, s.Opened)
+	assert.Equal(t, 90, s.Battery)
+	assert.Equal(t, 120, s.LinkQuality)
+	assert.NotNil(t, s.LastUpdated)
+	require.NotNil(t, s.LastAlarm)
+	assert.Equal(t, "opened", s.LastAlarm.Message)
+
+	all, err := m.Statuses(ctx)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, "door1", all[0].Name)
+	assert.Equal(t, "door2", all[1].Name)
+	assert.False(t, all[1].Available)
+
+	_, err = m.Status(ctx, "door3")
+	require.ErrorIs(t, err, alarm.ErrDeviceNotFound)
+}
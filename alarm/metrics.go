@@ -0,0 +1,13 @@
+package alarm
+
+import "github.com/SuddenGunter/hsd/metrics"
+
+// Results of alarm events.
+const (
+	resultSent       = "sent"
+	resultDebounced  = "debounced"
+	resultSuppressed = "suppressed"
+	resultBypassed   = "bypassed"
+)
+
+var alarmsTotal = metrics.NewCounter("hsd_alarms_total", "Alarm events by result: sent, debounced by the throttle policy, suppressed because the alarm is not armed, or bypassed.", "result")
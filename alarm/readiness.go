@@ -0,0 +1,124 @@
+package alarm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrNotReady is returned when arming while devices monitored in the mode are open or offline.
+var ErrNotReady = errors.New("not ready to arm")
+
+// Problems that prevent arming.
+const (
+	ProblemOpen    = "open"
+	ProblemOffline = "offline"
+)
+
+// readinessTimeout limits how long arming waits for device states.
+const readinessTimeout = 2 * time.Second
+
+type deviceStates interface {
+	Statuses(ctx context.Context) ([]DeviceStatus, error)
+	Bypass(ctx context.Context, device string, b Bypass) (DeviceStatus, error)
+}
+
+// NotReadyDevice is a device that prevents arming.
+type NotReadyDevice struct {
+	Name    string `json:"name"`
+	Problem string `json:"problem"`
+}
+
+// NotReadyError lists devices that prevent arming. It matches ErrNotReady.
+type NotReadyError struct {
+	Devices []NotReadyDevice
+}
+
+func (e *NotReadyError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrNotReady, formatNotReady(e.Devices))
+}
+
+// Is implements errors.Is.
+func (e *NotReadyError) Is(target error) bool {
+	return target == ErrNotReady
+}
+
+// CheckReadiness makes arming check states of devices monitored in the arm mode.
+// Without it, the alarm is armed regardless of device states.
+func (a *Alarmer) CheckReadiness(devices deviceStates) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	a.devices = devices
+}
+
+// notReady returns devices monitored in the mode that are open or offline, ignoring bypassed devices.
+// Device states are collected without holding a.mux, as device loops may be waiting for it in Alarm.
+func (a *Alarmer) notReady(mode Mode) []NotReadyDevice {
+	a.mux.Lock()
+	devices := a.devices
+	a.mux.Unlock()
+
+	if devices == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), readinessTimeout)
+	defer cancel()
+
+	statuses, err := devices.Statuses(ctx)
+	if err != nil {
+		// an unresponsive device must not prevent arming
+		a.l.Error("failed to check arming readiness, arming anyway", "err", err)
+		return nil
+	}
+
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	var notReady []NotReadyDevice
+
+	for _, s := range statuses {
+		if s.Bypass != nil || !a.monitoredIn(s.Name, mode) {
+			continue
+		}
+
+		switch {
+		case !s.Available:
+			notReady = append(notReady, NotReadyDevice{Name: s.Name, Problem: ProblemOffline})
+		case s.Opened:
+			notReady = append(notReady, NotReadyDevice{Name: s.Name, Problem: ProblemOpen})
+		}
+	}
+
+	return notReady
+}
+
+// bypassNotReady bypasses devices until the alarm is disarmed, so a forced arm doesn't alarm right away.
+func (a *Alarmer) bypassNotReady(notReady []NotReadyDevice, actor Actor) {
+	a.mux.Lock()
+	devices := a.devices
+	a.mux.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), readinessTimeout)
+	defer cancel()
+
+	for _, d := range notReady {
+		b := Bypass{Actor: actor.Name, Source: actor.Source, Reason: "armed while " + d.Problem}
+
+		if _, err := devices.Bypass(ctx, d.Name, b); err != nil {
+			a.l.Error("failed to bypass device when arming", "device", d.Name, "err", err)
+		}
+	}
+}
+
+func formatNotReady(devices []NotReadyDevice) string {
+	s := make([]string, 0, len(devices))
+	for _, d := range devices {
+		s = append(s, fmt.Sprintf("%s (%s)", d.Name, d.Problem))
+	}
+
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,56 @@
+package alarm_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlarmer_ArmReadiness(t *testing.T) {
+	t.Parallel()
+
+	a, m, n := newBypassMessenger(t)
+	a.CheckReadiness(m)
+
+	ctx := context.Background()
+
+	require.NoError(t, a.Disarm(alice))
+	m.SetData(ctx, "door1", alarm.SensorData{Opened: true})
+
+	err := a.Arm(alarm.ModeAway, alice)
+
+	var notReady *alarm.NotReadyError
+	require.ErrorAs(t, err, &notReady)
+	require.ErrorIs(t, err, alarm.ErrNotReady)
+	assert.Equal(t, []alarm.NotReadyDevice{{Name: "door1", Problem: alarm.ProblemOpen}}, notReady.Devices)
+	assert.Equal(t, alarm.StateDisarmed, a.State())
+
+	// devices not monitored in the mode don't prevent arming
+	a.SetDeviceModes("door1", []alarm.Mode{alarm.ModeAway})
+	require.NoError(t, a.Arm(alarm.ModeNight, alice))
+	require.NoError(t, a.Disarm(alice))
+	a.SetDeviceModes("door1", nil)
+
+	require.NoError(t, a.ForceArm(alarm.ModeAway, alice))
+	assert.Equal(t, alarm.StateArmedAway, a.State())
+	assert.Contains(t, n.messages(), "alarm: armed (away) by alice via api, bypassed: door1 (open)")
+
+	s, err := m.Status(ctx, "door1")
+	require.NoError(t, err)
+	require.NotNil(t, s.Bypass)
+	assert.Nil(t, s.Bypass.Until)
+
+	// the bypassed device neither alarms nor prevents re-arming
+	m.SetData(ctx, "door1", alarm.SensorData{Opened: true})
+	require.NoError(t, a.Arm(alarm.ModeHome, alice))
+	assert.NotContains(t, n.messages(), "door1: opened")
+
+	require.NoError(t, a.Disarm(alice))
+
+	s, err = m.Status(ctx, "door1")
+	require.NoError(t, err)
+	assert.Nil(t, s.Bypass)
+}
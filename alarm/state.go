@@ -0,0 +1,119 @@
+package alarm
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// State of the alarm.
+type State string
+
+// Supported alarm states. Names match Home Assistant alarm_control_panel states.
+const (
+	StateDisarmed   State = "disarmed"
+	StateArming     State = "arming"
+	StateArmedHome  State = "armed_home"
+	StateArmedAway  State = "armed_away"
+	StateArmedNight State = "armed_night"
+	StatePending    State = "pending"
+	StateTriggered  State = "triggered"
+)
+
+// States lists all alarm states.
+var States = []State{StateDisarmed, StateArming, StateArmedHome, StateArmedAway, StateArmedNight, StatePending, StateTriggered}
+
+// Mode selects which devices are monitored while the alarm is armed.
+type Mode string
+
+// Supported arm modes.
+const (
+	ModeHome  Mode = "home"
+	ModeAway  Mode = "away"
+	ModeNight Mode = "night"
+)
+
+// Sources of state changes.
+const (
+	SourceSystem        = "system"
+	SourceAPI           = "api"
+	SourceHomeAssistant = "homeassistant"
+	SourceDevice        = "device"
+)
+
+// Actor identifies who requested a state change and through which source.
+type Actor struct {
+	// Name of the user or device, empty if the source has no notion of users.
+	Name   string
+	Source string
+}
+
+// String returns "name via source", or just the source if the name is empty.
+func (a Actor) String() string {
+	if a.Name == "" {
+		return a.Source
+	}
+
+	return fmt.Sprintf("%s via %s", a.Name, a.Source)
+}
+
+// ErrInvalidTransition is returned when the requested state change is not allowed from the current state.
+var ErrInvalidTransition = errors.New("invalid state transition")
+
+// ErrUnknownMode is returned when parsing an unsupported arm mode.
+var ErrUnknownMode = errors.New("unknown arm mode")
+
+// ParseMode parses an arm mode. Empty string defaults to away mode.
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case "", ModeAway:
+		return ModeAway, nil
+	case ModeHome:
+		return ModeHome, nil
+	case ModeNight:
+		return ModeNight, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownMode, s)
+	}
+}
+
+func (m Mode) armedState() State {
+	switch m {
+	case ModeHome:
+		return StateArmedHome
+	case ModeNight:
+		return StateArmedNight
+	default:
+		return StateArmedAway
+	}
+}
+
+// Transition is a recorded change of the alarm state.
+type Transition struct {
+	From   State     `json:"from"`
+	To     State     `json:"to"`
+	Actor  string    `json:"actor,omitempty"`
+	Source string    `json:"source"`
+	Reason string    `json:"reason"`
+	Time   time.Time `json:"time"`
+}
+
+// transitions lists allowed state changes. Re-arming an armed alarm (e.g. to change the mode) is allowed.
+var transitions = map[State][]State{
+	StateDisarmed:   {StateArming, StateArmedHome, StateArmedAway, StateArmedNight},
+	StateArming:     {StateDisarmed, StateArming, StateArmedHome, StateArmedAway, StateArmedNight},
+	StateArmedHome:  {StateDisarmed, StateArming, StateArmedHome, StateArmedAway, StateArmedNight, StatePending, StateTriggered},
+	StateArmedAway:  {StateDisarmed, StateArming, StateArmedHome, StateArmedAway, StateArmedNight, StatePending, StateTriggered},
+	StateArmedNight: {StateDisarmed, StateArming, StateArmedHome, StateArmedAway, StateArmedNight, StatePending, StateTriggered},
+	StatePending:    {StateDisarmed, StateTriggered},
+	StateTriggered:  {StateDisarmed, StateArming, StateArmedHome, StateArmedAway, StateArmedNight},
+}
+
+func canTransition(from, to State) bool {
+	return slices.Contains(transitions[from], to)
+}
+
+func (s State) armed() bool {
+	return s == StateArmedHome || s == StateArmedAway || s == StateArmedNight
+}
@@ -0,0 +1,223 @@
+package alarm
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SuddenGunter/hsd/notify/message"
+)
+
+// ErrInvalidThrottle is returned for throttle policies that can't work.
+var ErrInvalidThrottle = errors.New("invalid throttle policy")
+
+// Edge decides which alarms of a throttle window are sent.
+type Edge string
+
+const (
+	// EdgeLeading sends the first Max alarms of a window right away and a summary of the rest when it ends.
+	EdgeLeading Edge = "leading"
+	// EdgeTrailing sends the last alarm of a window when it ends.
+	EdgeTrailing Edge = "trailing"
+)
+
+// ThrottleKey decides which alarms of a device share a throttle window.
+type ThrottleKey string
+
+const (
+	// ThrottleByDevice throttles all alarms of a device together.
+	ThrottleByDevice ThrottleKey = "device"
+	// ThrottleByMessage throttles each kind of alarm separately, so an unavailable device alarm
+	// is not dropped because it was opened a moment ago.
+	ThrottleByMessage ThrottleKey = "message"
+)
+
+// Alarm messages of devices.
+const (
+	MessageOpened      = "opened"
+	MessageUnavailable = "unavailable"
+	MessageStale       = "no messages received for a long time"
+)
+
+// Alarm kinds that throttle overrides can target besides device names.
+const (
+	KindOpened      = "opened"
+	KindUnavailable = "unavailable"
+	KindStale       = "stale"
+)
+
+var messageKinds = map[string]string{
+	MessageOpened:      KindOpened,
+	MessageUnavailable: KindUnavailable,
+	MessageStale:       KindStale,
+}
+
+// ThrottlePolicy limits how often a device alarms. A zero Window disables throttling.
+type ThrottlePolicy struct {
+	Window time.Duration
+	// Max is the number of alarms sent per window on the leading edge. It is ignored on the trailing edge.
+	Max  int
+	Edge Edge
+	Key  ThrottleKey
+}
+
+// Validate returns an error if the policy can't work.
+func (p ThrottlePolicy) Validate() error {
+	switch {
+	case p.Window < 0:
+		return fmt.Errorf("%w: window must not be negative", ErrInvalidThrottle)
+	case p.Edge != EdgeLeading && p.Edge != EdgeTrailing:
+		return fmt.Errorf("%w: unknown edge %q", ErrInvalidThrottle, p.Edge)
+	case p.Key != ThrottleByDevice && p.Key != ThrottleByMessage:
+		return fmt.Errorf("%w: unknown key %q", ErrInvalidThrottle, p.Key)
+	case p.Edge == EdgeLeading && p.Max < 1:
+		return fmt.Errorf("%w: max must be positive", ErrInvalidThrottle)
+	}
+
+	return nil
+}
+
+// ParseThrottleOverride parses "target:window[:max[:edge[:key]]]" entries, where target is a device name
+// or an alarm kind: opened, unavailable or stale. Omitted fields are taken from def.
+func ParseThrottleOverride(entry string, def ThrottlePolicy) (string, ThrottlePolicy, error) {
+	fields := strings.Split(entry, ":")
+	if len(fields) < 2 || len(fields) > 5 || fields[0] == "" {
+		return "", ThrottlePolicy{}, fmt.Errorf("%w: %q: expected target:window[:max[:edge[:key]]]", ErrInvalidThrottle, entry)
+	}
+
+	target, p := fields[0], def
+
+	window, err := time.ParseDuration(fields[1])
+	if err != nil {
+		return "", ThrottlePolicy{}, fmt.Errorf("%w: %s: %w", ErrInvalidThrottle, target, err)
+	}
+
+	p.Window = window
+
+	if len(fields) > 2 {
+		if p.Max, err = strconv.Atoi(fields[2]); err != nil {
+			return "", ThrottlePolicy{}, fmt.Errorf("%w: %s: max: %w", ErrInvalidThrottle, target, err)
+		}
+	}
+
+	if len(fields) > 3 {
+		p.Edge = Edge(fields[3])
+	}
+
+	if len(fields) > 4 {
+		p.Key = ThrottleKey(fields[4])
+	}
+
+	if err := p.Validate(); err != nil {
+		return "", ThrottlePolicy{}, fmt.Errorf("%s: %w", target, err)
+	}
+
+	return target, p, nil
+}
+
+// throttler limits alarms of a single device according to its policies.
+type throttler struct {
+	next     alarmer
+	notifier notifier
+	clock    Clock
+	policy   func(message string) ThrottlePolicy
+	// alerting reports whether alarms of the device are still acted on when a window ends.
+	alerting func() bool
+	l        *slog.Logger
+
+	mux     *sync.Mutex
+	windows map[string]*throttleWindow
+}
+
+// throttleWindow counts alarms sharing a key until the window ends.
+type throttleWindow struct {
+	policy     ThrottlePolicy
+	sent       int
+	suppressed int
+	last       string
+}
+
+func newThrottler(next alarmer, notifier notifier, clock Clock, policy func(message string) ThrottlePolicy, alerting func() bool, l *slog.Logger) *throttler {
+	return &throttler{
+		next:     next,
+		notifier: notifier,
+		clock:    clock,
+		policy:   policy,
+		alerting: alerting,
+		l:        l,
+		mux:      &sync.Mutex{},
+		windows:  make(map[string]*throttleWindow),
+	}
+}
+
+func (t *throttler) Alarm(device, message string) {
+	p := t.policy(message)
+	if p.Window <= 0 {
+		t.next.Alarm(device, message)
+		return
+	}
+
+	key := ""
+	if p.Key == ThrottleByMessage {
+		key = message
+	}
+
+	t.mux.Lock()
+
+	w, ok := t.windows[key]
+	if !ok {
+		w = &throttleWindow{policy: p}
+		t.windows[key] = w
+		t.clock.AfterFunc(p.Window, func() { t.endWindow(device, key) })
+	}
+
+	if w.policy.Edge == EdgeLeading && w.sent < w.policy.Max {
+		w.sent++
+		t.mux.Unlock()
+
+		t.next.Alarm(device, message)
+
+		return
+	}
+
+	w.suppressed++
+	w.last = message
+	t.mux.Unlock()
+
+	t.l.Info("alarm event received, but got throttled", "device", device, "message", message)
+	alarmsTotal.Inc(resultDebounced)
+}
+
+// endWindow sends the alarm the window held back on the trailing edge and notifies about the suppressed ones.
+// Nothing is sent if the device was bypassed or the alarm disarmed in the meantime.
+func (t *throttler) endWindow(device, key string) {
+	t.mux.Lock()
+	w := t.windows[key]
+	delete(t.windows, key)
+	t.mux.Unlock()
+
+	if w.suppressed == 0 {
+		return
+	}
+
+	if !t.alerting() {
+		t.l.Info("throttle window ended, but alarms of the device are no longer acted on", "device", device, "suppressed", w.suppressed)
+		return
+	}
+
+	suppressed := w.suppressed
+	if w.policy.Edge == EdgeTrailing {
+		// the last alarm is sent, only the ones before it are suppressed
+		suppressed--
+
+		t.next.Alarm(device, w.last)
+	}
+
+	if suppressed > 0 {
+		t.notifier.Notify(message.New(message.KeySuppressed, message.Warning, device, "reason", w.last, "suppressed", strconv.Itoa(suppressed)))
+	}
+}
@@ -0,0 +1,224 @@
+package alarm_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	"github.com/SuddenGunter/hsd/alarm/alarmtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeAlarmer struct {
+	mux    sync.Mutex
+	alarms []string
+}
+
+func (a *fakeAlarmer) Alarm(device, message string) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	a.alarms = append(a.alarms, device+": "+message)
+}
+
+func (a *fakeAlarmer) sent() []string {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	return append([]string(nil), a.alarms...)
+}
+
+func newThrottled(t *testing.T, opts alarm.MessengerOptions) (*alarm.DeviceMessenger, *fakeAlarmer, *fakeNotifier, *alarmtest.Clock) {
+	t.Helper()
+
+	a, n, c := &fakeAlarmer{}, &fakeNotifier{}, &alarmtest.Clock{}
+	opts.Clock = c
+
+	m := alarm.NewDeviceMessenger([]string{"door1"}, a, n, nopRecorder{}, opts, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	m.Listen()
+
+	t.Cleanup(m.Close)
+
+	return m, a, n, c
+}
+
+// setOpened sends a sensor update and waits for the device loop to process it.
+func setOpened(t *testing.T, m *alarm.DeviceMessenger, opened bool) {
+	t.Helper()
+
+	m.SetData(context.Background(), "door1", alarm.SensorData{Opened: opened})
+
+	_, err := m.Status(context.Background(), "door1")
+	require.NoError(t, err)
+}
+
+// disconnect makes the device unavailable and waits for the device loop to process it.
+func disconnect(t *testing.T, m *alarm.DeviceMessenger) {
+	t.Helper()
+
+	m.SetAvailability(context.Background(), "door1", false)
+
+	_, err := m.Status(context.Background(), "door1")
+	require.NoError(t, err)
+}
+
+var defaultThrottle = alarm.ThrottlePolicy{Window: time.Second, Max: 1, Edge: alarm.EdgeLeading, Key: alarm.ThrottleByMessage}
+
+func TestThrottle_LeadingByMessage(t *testing.T) {
+	t.Parallel()
+
+	m, a, n, c := newThrottled(t, alarm.MessengerOptions{Throttle: defaultThrottle})
+
+	setOpened(t, m, true)
+	setOpened(t, m, true)
+	setOpened(t, m, true)
+	assert.Equal(t, []string{"door1: opened"}, a.sent())
+
+	// a different condition is not throttled by the previous one
+	setOpened(t, m, false)
+	disconnect(t, m)
+	assert.Equal(t, []string{"door1: opened", "door1: unavailable"}, a.sent())
+
+	// the suppressed alarms are summarized in a notification, not raised again
+	c.Advance(time.Second)
+	assert.Equal(t, []string{"door1: opened", "door1: unavailable"}, a.sent())
+	assert.Equal(t, []string{"door1: opened, 2 more suppressed"}, n.messages())
+
+	// a new window starts after the previous one ended
+	setOpened(t, m, true)
+	assert.Len(t, a.sent(), 3)
+}
+
+func TestThrottle_TrailingByDevice(t *testing.T) {
+	t.Parallel()
+
+	p := alarm.ThrottlePolicy{Window: time.Minute, Edge: alarm.EdgeTrailing, Key: alarm.ThrottleByDevice}
+	m, a, n, c := newThrottled(t, alarm.MessengerOptions{Throttle: p})
+
+	setOpened(t, m, true)
+	setOpened(t, m, true)
+	setOpened(t, m, false)
+	disconnect(t, m)
+	assert.Empty(t, a.sent())
+
+	c.Advance(30 * time.Second)
+	assert.Empty(t, a.sent())
+
+	c.Advance(30 * time.Second)
+	assert.Equal(t, []string{"door1: unavailable"}, a.sent())
+	assert.Equal(t, []string{"door1: unavailable, 2 more suppressed"}, n.messages())
+}
+
+func TestThrottle_Overrides(t *testing.T) {
+	t.Parallel()
+
+	m, a, n, c := newThrottled(t, alarm.MessengerOptions{
+		Throttle: defaultThrottle,
+		ThrottleOverrides: map[string]alarm.ThrottlePolicy{
+			alarm.KindOpened: {Window: time.Minute, Max: 2, Edge: alarm.EdgeLeading, Key: alarm.ThrottleByMessage},
+		},
+	})
+
+	setOpened(t, m, true)
+	setOpened(t, m, true)
+	setOpened(t, m, true)
+	assert.Equal(t, []string{"door1: opened", "door1: opened"}, a.sent())
+
+	c.Advance(time.Second)
+	assert.Len(t, a.sent(), 2, "the opened window is a minute long")
+
+	c.Advance(time.Minute)
+	assert.Len(t, a.sent(), 2)
+	assert.Equal(t, []string{"door1: opened, 1 more suppressed"}, n.messages())
+}
+
+func TestThrottle_BypassedDuringWindow(t *testing.T) {
+	t.Parallel()
+
+	p := alarm.ThrottlePolicy{Window: time.Minute, Edge: alarm.EdgeTrailing, Key: alarm.ThrottleByDevice}
+	m, a, n, c := newThrottled(t, alarm.MessengerOptions{Throttle: p})
+
+	setOpened(t, m, true)
+	setOpened(t, m, true)
+
+	_, err := m.Bypass(context.Background(), "door1", alarm.Bypass{Actor: "alice", Source: alarm.SourceAPI})
+	require.NoError(t, err)
+
+	// the held back alarm is dropped, the device was bypassed before the window ended
+	c.Advance(time.Minute)
+	assert.Empty(t, a.sent())
+	assert.Len(t, n.messages(), 1, "only the bypass is notified")
+}
+
+func TestThrottle_DisarmedDuringWindow(t *testing.T) {
+	t.Parallel()
+
+	a, n := newAlarmer(t, alarm.Options{})
+	c := &alarmtest.Clock{}
+
+	m := alarm.NewDeviceMessenger([]string{"door1"}, a, n, nopRecorder{}, alarm.MessengerOptions{Throttle: defaultThrottle, Clock: c}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	m.ObserveAlarm(a)
+	m.Listen()
+
+	t.Cleanup(m.Close)
+
+	setOpened(t, m, true)
+	setOpened(t, m, true)
+	require.Equal(t, alarm.StateTriggered, a.State())
+	require.NoError(t, a.Disarm(alarm.Actor{Name: "alice", Source: alarm.SourceAPI}))
+
+	c.Advance(time.Second)
+	assert.Equal(t, []string{"door1: opened", "alarm: disarmed by alice via api"}, n.messages())
+}
+
+func TestThrottle_Disabled(t *testing.T) {
+	t.Parallel()
+
+	m, a, _, _ := newThrottled(t, alarm.MessengerOptions{})
+
+	setOpened(t, m, true)
+	setOpened(t, m, true)
+	assert.Len(t, a.sent(), 2)
+}
+
+func TestParseThrottleOverride(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		entry  string
+		target string
+		want   alarm.ThrottlePolicy
+		err    bool
+	}{
+		{entry: "door1:10s", target: "door1", want: alarm.ThrottlePolicy{Window: 10 * time.Second, Max: 1, Edge: alarm.EdgeLeading, Key: alarm.ThrottleByMessage}},
+		{entry: "unavailable:10m:3:trailing:device", target: "unavailable", want: alarm.ThrottlePolicy{Window: 10 * time.Minute, Max: 3, Edge: alarm.EdgeTrailing, Key: alarm.ThrottleByDevice}},
+		{entry: "door1:0s", target: "door1", want: alarm.ThrottlePolicy{Max: 1, Edge: alarm.EdgeLeading, Key: alarm.ThrottleByMessage}},
+		{entry: "door1", err: true},
+		{entry: ":1s", err: true},
+		{entry: "door1:soon", err: true},
+		{entry: "door1:1s:0", err: true},
+		{entry: "door1:1s:1:middle", err: true},
+		{entry: "door1:1s:1:leading:room", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.entry, func(t *testing.T) {
+			t.Parallel()
+
+			target, p, err := alarm.ParseThrottleOverride(tt.entry, defaultThrottle)
+			if tt.err {
+				require.ErrorIs(t, err, alarm.ErrInvalidThrottle)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.target, target)
+			assert.Equal(t, tt.want, p)
+		})
+	}
+}
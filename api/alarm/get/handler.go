@@ -1,11 +1,11 @@
 package alarmgethandler
 
 import (
-	"encoding/json"
 	"log/slog"
 	"net/http"
 
 	"github.com/SuddenGunter/hsd/alarm"
+	"github.com/SuddenGunter/hsd/api/httpjson"
 )
 
 // GetHandler handles GET requests to /alarm.
@@ -21,18 +21,17 @@ func NewGetHandler(l *slog.Logger, alarmer *alarm.Alarmer) *GetHandler {
 
 // ServeHTTP handles the request.
 func (h *GetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	v := h.alarmer.Enabled()
-
-	resp, err := json.Marshal(map[string]bool{"enabled": v})
-	if err != nil {
-		h.l.Error("failed to marshal response", "err", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
-
-		return
-	}
+	httpjson.Write(h.l, w, r, http.StatusOK, response{
+		Enabled: h.alarmer.Enabled(),
+		State:   h.alarmer.State(),
+		Mode:    h.alarmer.Mode(),
+		History: h.alarmer.History(),
+	})
+}
 
-	_, err = w.Write(resp)
-	if err != nil {
-		h.l.Warn("failed to write response", "err", err, "path", r.URL.Path)
-	}
+type response struct {
+	Enabled bool               `json:"enabled"`
+	State   alarm.State        `json:"state"`
+	Mode    alarm.Mode         `json:"mode"`
+	History []alarm.Transition `json:"history"`
 }
@@ -1,12 +1,19 @@
 package alarmposthandler
 
 import (
-	"encoding/json"
-	"io"
+	"errors"
 	"log/slog"
 	"net/http"
 
 	"github.com/SuddenGunter/hsd/alarm"
+	"github.com/SuddenGunter/hsd/api/auth"
+	"github.com/SuddenGunter/hsd/api/httpjson"
+)
+
+var (
+	errEnabledRequired = errors.New("enabled is required")
+	errModeNotAllowed  = errors.New("mode is only allowed when arming")
+	errForceNotAllowed = errors.New("force is only allowed when arming")
 )
 
 // PostHandler handles POST requests to /alarm.
@@ -22,42 +29,100 @@ func NewPostHandler(l *slog.Logger, alarmer *alarm.Alarmer) *PostHandler {
 
 // ServeHTTP handles the request.
 func (h *PostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		h.l.Error("failed to read request body", "err", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
-
+	var req request
+	if !httpjson.Decode(w, r, &req) {
 		return
 	}
 
-	var req struct {
-		Enabled bool `json:"enabled"`
+	scope := auth.ScopeDisarm
+	if *req.Enabled {
+		scope = auth.ScopeArm
 	}
 
-	err = json.Unmarshal(body, &req)
-	if err != nil {
-		h.l.Error("failed to unmarshal request", "err", err)
-		http.Error(w, "bad request", http.StatusBadRequest)
+	if !auth.Allowed(r.Context(), scope) {
+		auth.Forbidden(w, scope)
 
 		return
 	}
 
-	if req.Enabled {
-		h.alarmer.Enable()
-	} else {
-		h.alarmer.Disable()
+	actor := alarm.Actor{Source: alarm.SourceAPI}
+	if id, ok := auth.FromContext(r.Context()); ok {
+		actor.Name = id.Name
+	}
+
+	var err error
+
+	switch {
+	case *req.Enabled && req.Force:
+		err = h.alarmer.ForceArm(req.mode, actor)
+	case *req.Enabled:
+		err = h.alarmer.Arm(req.mode, actor)
+	default:
+		err = h.alarmer.Disarm(actor)
+	}
+
+	var notReady *alarm.NotReadyError
+	if errors.As(err, &notReady) {
+		h.l.Warn("alarm state change rejected", "err", err)
+		httpjson.Write(h.l, w, r, http.StatusConflict, notReadyResponse{
+			ErrorResponse: httpjson.ErrorResponse{Status: http.StatusConflict, Error: err.Error()},
+			Devices:       notReady.Devices,
+		})
+
+		return
 	}
 
-	resp, err := json.Marshal(map[string]bool{"enabled": req.Enabled})
 	if err != nil {
-		h.l.Error("failed to marshal response", "err", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
+		h.l.Warn("alarm state change rejected", "err", err)
+		httpjson.Error(w, http.StatusConflict, err.Error())
 
 		return
 	}
 
-	_, err = w.Write(resp)
+	httpjson.Write(h.l, w, r, http.StatusOK, response{Enabled: h.alarmer.Enabled(), State: h.alarmer.State()})
+}
+
+type request struct {
+	Enabled *bool  `json:"enabled"`
+	Mode    string `json:"mode"`
+	// Force arms even if devices are open or offline, bypassing them until the alarm is disarmed.
+	Force bool `json:"force"`
+
+	mode alarm.Mode
+}
+
+// Validate implements httpjson.Validator.
+func (req *request) Validate() error {
+	if req.Enabled == nil {
+		return errEnabledRequired
+	}
+
+	if !*req.Enabled && req.Mode != "" {
+		return errModeNotAllowed
+	}
+
+	if !*req.Enabled && req.Force {
+		return errForceNotAllowed
+	}
+
+	mode, err := alarm.ParseMode(req.Mode)
 	if err != nil {
-		h.l.Warn("failed to write response", "err", err, "path", r.URL.Path)
+		return err
 	}
+
+	req.mode = mode
+
+	return nil
+}
+
+type response struct {
+	Enabled bool        `json:"enabled"`
+	State   alarm.State `json:"state"`
+}
+
+// notReadyResponse lists devices that prevent arming.
+type notReadyResponse struct {
+	httpjson.ErrorResponse
+
+	Devices []alarm.NotReadyDevice `json:"devices"`
 }
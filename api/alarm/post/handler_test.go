@@ -0,0 +1,136 @@
+package alarmposthandler_test
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	alarmposthandler "github.com/SuddenGunter/hsd/api/alarm/post"
+	"github.com/SuddenGunter/hsd/api/auth"
+	"github.com/SuddenGunter/hsd/api/httpjson"
+	"github.com/SuddenGunter/hsd/audit"
+	"github.com/SuddenGunter/hsd/event"
+	"github.com/SuddenGunter/hsd/notify/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type nopNotifier struct{}
+
+func (nopNotifier) Notify(message.Message) {}
+
+type nopRecorder struct{}
+
+func (nopRecorder) Record(event.Event) {}
+
+type nopAuditor struct{}
+
+func (nopAuditor) Audit(audit.Entry) {}
+
+func TestPostHandler_Auth(t *testing.T) {
+	t.Parallel()
+
+	tokens, err := auth.NewTokenAuthenticator([]string{"reader:t1:read", "armer:t2:arm", "owner:t3:arm+disarm"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		token  string
+		body   string
+		status int
+		state  alarm.State
+	}{
+		{name: "no token", body: `{"enabled":false}`, status: http.StatusUnauthorized, state: alarm.StateArmedAway},
+		{name: "wrong token", token: "t4", body: `{"enabled":false}`, status: http.StatusUnauthorized, state: alarm.StateArmedAway},
+		{name: "read only", token: "t1", body: `{"enabled":true}`, status: http.StatusForbidden, state: alarm.StateArmedAway},
+		{name: "arm only disarms", token: "t2", body: `{"enabled":false}`, status: http.StatusForbidden, state: alarm.StateArmedAway},
+		{name: "arm only arms", token: "t2", body: `{"enabled":true,"mode":"home"}`, status: http.StatusOK, state: alarm.StateArmedHome},
+		{name: "owner disarms", token: "t3", body: `{"enabled":false}`, status: http.StatusOK, state: alarm.StateDisarmed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			l := slog.New(slog.NewTextHandler(io.Discard, nil))
+			a := alarm.New(nopNotifier{}, nopRecorder{}, nopAuditor{}, alarm.Options{}, l)
+			h := auth.NewMiddleware(l, tokens).Authenticate(alarmposthandler.NewPostHandler(l, a))
+
+			req := httptest.NewRequest(http.MethodPost, "/alarm", strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, tt.state, a.State())
+		})
+	}
+}
+
+func TestPostHandler_Validation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		err    string
+	}{
+		{name: "empty body", body: ``, err: "invalid request: empty body"},
+		{name: "empty object", body: `{}`, err: "invalid request: enabled is required"},
+		{name: "wrong type", body: `{"enabled":"yes"}`},
+		{name: "unknown field", body: `{"enabled":true,"bypass":true}`},
+		{name: "force when disarming", body: `{"enabled":false,"force":true}`, err: "invalid request: force is only allowed when arming"},
+		{name: "trailing data", body: `{"enabled":true}{}`},
+		{name: "mode when disarming", body: `{"enabled":false,"mode":"home"}`, err: "invalid request: mode is only allowed when arming"},
+		{name: "unknown mode", body: `{"enabled":true,"mode":"vacation"}`},
+		{
+			name:   "too large",
+			body:   `{"enabled":true,"mode":"` + strings.Repeat("a", httpjson.MaxBodySize) + `"}`,
+			status: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			l := slog.New(slog.NewTextHandler(io.Discard, nil))
+			a := alarm.New(nopNotifier{}, nopRecorder{}, nopAuditor{}, alarm.Options{}, l)
+			m := auth.NewMiddleware(l)
+			m.Disable()
+
+			h := m.Authenticate(alarmposthandler.NewPostHandler(l, a))
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/alarm", strings.NewReader(tt.body)))
+
+			var resp httpjson.ErrorResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+
+			status := tt.status
+			if status == 0 {
+				status = http.StatusBadRequest
+			}
+
+			assert.Equal(t, status, rec.Code)
+
+			assert.Equal(t, rec.Code, resp.Status)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+			if tt.err != "" {
+				assert.Equal(t, tt.err, resp.Error)
+			}
+
+			assert.Equal(t, alarm.StateArmedAway, a.State())
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SuddenGunter/hsd/api/httpjson"
+)
+
+// Scope grants access to a group of API operations.
+type Scope string
+
+// Supported scopes.
+const (
+	ScopeRead   Scope = "read"
+	ScopeArm    Scope = "arm"
+	ScopeDisarm Scope = "disarm"
+	// ScopeAdmin allows managing devices.
+	ScopeAdmin Scope = "admin"
+)
+
+var allScopes = []Scope{ScopeRead, ScopeArm, ScopeDisarm, ScopeAdmin}
+
+// ErrInvalidCredentials is returned when credentials config can't be parsed.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Identity of an authenticated caller.
+type Identity struct {
+	Name   string
+	Scopes []Scope
+}
+
+// Has returns true if the identity was granted the scope.
+func (i Identity) Has(s Scope) bool {
+	return slices.Contains(i.Scopes, s)
+}
+
+// Authenticator verifies credentials of the request.
+// It returns false if the request has no credentials it understands, and an error if the credentials are wrong.
+type Authenticator interface {
+	Authenticate(r *http.Request) (Identity, bool, error)
+	// Challenge is the WWW-Authenticate header value sent with 401 responses.
+	Challenge() string
+}
+
+type ctxKey struct{}
+
+// WithIdentity returns a copy of ctx with the caller identity.
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the caller identity set by Middleware.
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(ctxKey{}).(Identity)
+	return id, ok
+}
+
+// Allowed returns true if the caller was granted the scope.
+func Allowed(ctx context.Context, s Scope) bool {
+	id, ok := FromContext(ctx)
+	return ok && id.Has(s)
+}
+
+// Middleware authenticates requests and writes an audit log of state changes.
+// Without authenticators, all requests are rejected unless authentication is disabled.
+type Middleware struct {
+	authenticators []Authenticator
+	limiter        *Limiter
+	disabled       bool
+	l              *slog.Logger
+}
+
+// NewMiddleware returns a new Middleware.
+func NewMiddleware(l *slog.Logger, authenticators ...Authenticator) *Middleware {
+	return &Middleware{authenticators: authenticators, l: l}
+}
+
+// Disable lets all requests in as an anonymous caller with all scopes.
+// Must be called before the middleware serves requests.
+func (m *Middleware) Disable() {
+	m.l.Warn("api authentication is disabled, anyone who can reach the api can disarm the alarm")
+	m.disabled = true
+}
+
+// Limit rate limits state changing requests and locks clients out after repeated authentication failures
+// of any request, so read only endpoints can't be used to guess credentials.
+// Must be called before the middleware serves requests.
+func (m *Middleware) Limit(lim *Limiter) {
+	m.limiter = lim
+}
+
+// Authenticate requires the caller to be authenticated. Handlers check scopes themselves.
+func (m *Middleware) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if retry, ok := m.admit(r); !ok {
+			m.l.Warn("api request rate limited", "remote", r.RemoteAddr, "path", r.URL.Path)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+			httpjson.Error(w, http.StatusTooManyRequests, "too many requests")
+
+			return
+		}
+
+		id, ok := m.authenticate(w, r)
+		if !ok {
+			return
+		}
+
+		m.serve(w, r.WithContext(WithIdentity(r.Context(), id)), id, next)
+	})
+}
+
+// Require requires the caller to be authenticated and granted the scope.
+func (m *Middleware) Require(s Scope, next http.Handler) http.Handler {
+	return m.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !Allowed(r.Context(), s) {
+			Forbidden(w, s)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// Forbidden writes a 403 response.
+func Forbidden(w http.ResponseWriter, s Scope) {
+	httpjson.Error(w, http.StatusForbidden, fmt.Sprintf("forbidden: %s scope required", s))
+}
+
+func (m *Middleware) authenticate(w http.ResponseWriter, r *http.Request) (Identity, bool) {
+	if m.disabled {
+		return Identity{Name: "anonymous", Scopes: allScopes}, true
+	}
+
+	for _, a := range m.authenticators {
+		id, ok, err := a.Authenticate(r)
+		if err != nil {
+			m.l.Warn("authentication failed", "err", err, "remote", r.RemoteAddr, "path", r.URL.Path)
+			m.unauthorized(w)
+
+			if m.limiter != nil {
+				m.limiter.Failure(clientAddr(r))
+			}
+
+			return Identity{}, false
+		}
+
+		if ok {
+			return id, true
+		}
+	}
+
+	m.unauthorized(w)
+
+	return Identity{}, false
+}
+
+// admit returns false and how long to wait if the client is locked out or, for state changing requests,
+// exceeded the rate limit.
+func (m *Middleware) admit(r *http.Request) (time.Duration, bool) {
+	switch {
+	case m.limiter == nil:
+		return 0, true
+	case readOnly(r):
+		retry, locked := m.limiter.Locked(clientAddr(r))
+		return retry, !locked
+	default:
+		return m.limiter.Allow(clientAddr(r))
+	}
+}
+
+func readOnly(r *http.Request) bool {
+	return r.Method == http.MethodGet || r.Method == http.MethodHead
+}
+
+func (m *Middleware) unauthorized(w http.ResponseWriter) {
+	for _, a := range m.authenticators {
+		w.Header().Add("WWW-Authenticate", a.Challenge())
+	}
+
+	httpjson.Error(w, http.StatusUnauthorized, "unauthorized")
+}
+
+// serve calls next and logs state changing requests along with the caller identity.
+func (m *Middleware) serve(w http.ResponseWriter, r *http.Request, id Identity, next http.Handler) {
+	if readOnly(r) {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	next.ServeHTTP(sw, r)
+
+	m.l.Info("audit", "caller", id.Name, "method", r.Method, "path", r.URL.Path, "status", sw.status, "remote", r.RemoteAddr)
+}
+
+// ParseScopes parses scopes separated by '+'.
+func ParseScopes(s string) ([]Scope, error) {
+	var scopes []Scope
+
+	for v := range strings.SplitSeq(s, "+") {
+		if !slices.Contains(allScopes, Scope(v)) {
+			return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidCredentials, v)
+		}
+
+		scopes = append(scopes, Scope(v))
+	}
+
+	return scopes, nil
+}
+
+// splitCredential splits "name:secret:scopes" entries. Secret may contain ':'.
+func splitCredential(entry string) (string, string, []Scope, error) {
+	name, rest, ok := strings.Cut(entry, ":")
+	if !ok || name == "" {
+		return "", "", nil, fmt.Errorf("%w: expected name:secret:scopes", ErrInvalidCredentials)
+	}
+
+	i := strings.LastIndex(rest, ":")
+	if i <= 0 {
+		return "", "", nil, fmt.Errorf("%w: %s: expected name:secret:scopes", ErrInvalidCredentials, name)
+	}
+
+	scopes, err := ParseScopes(rest[i+1:])
+	if err != nil {
+		return "", "", nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return name, rest[:i], scopes, nil
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,159 @@
+package auth_test
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SuddenGunter/hsd/api/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+var ok = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	id, _ := auth.FromContext(r.Context())
+	_, _ = w.Write([]byte(id.Name))
+})
+
+func TestMiddleware_BearerToken(t *testing.T) {
+	t.Parallel()
+
+	a, err := auth.NewTokenAuthenticator([]string{"alice:s3cr:et:read+arm", "bob:token2:disarm"})
+	require.NoError(t, err)
+
+	h := auth.NewMiddleware(discard(), a).Require(auth.ScopeRead, ok)
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+		body   string
+	}{
+		{name: "no credentials", status: http.StatusUnauthorized},
+		{name: "unknown token", header: "Bearer nope", status: http.StatusUnauthorized},
+		{name: "missing scope", header: "Bearer token2", status: http.StatusForbidden},
+		{name: "allowed", header: "Bearer s3cr:et", status: http.StatusOK, body: "alice"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/alarm", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code)
+
+			if tt.status == http.StatusUnauthorized {
+				assert.Equal(t, `Bearer realm="hsd"`, rec.Header().Get("WWW-Authenticate"))
+			}
+
+			if tt.body != "" {
+				assert.Equal(t, tt.body, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestMiddleware_BasicAuth(t *testing.T) {
+	t.Parallel()
+
+	hash, err := auth.HashPassword("hunter2")
+	require.NoError(t, err)
+
+	a, err := auth.NewBasicAuthenticator([]string{"alice:" + hash + ":read"})
+	require.NoError(t, err)
+
+	h := auth.NewMiddleware(discard(), a).Require(auth.ScopeRead, ok)
+
+	req := httptest.NewRequest(http.MethodGet, "/alarm", nil)
+	req.SetBasicAuth("alice", "hunter2")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "alice", rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/alarm", nil)
+	req.SetBasicAuth("alice", "hunter3")
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestNewBasicAuthenticator_Hashes(t *testing.T) {
+	t.Parallel()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	// htpasswd -B writes $2y$ hashes, which only differ in the prefix
+	htpasswd := "$2y$" + strings.TrimPrefix(string(hash), "$2a$")
+
+	a, err := auth.NewBasicAuthenticator([]string{"alice:" + htpasswd + ":read"})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/alarm", nil)
+	req.SetBasicAuth("alice", "hunter2")
+
+	id, ok, err := a.Authenticate(req)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "alice", id.Name)
+
+	for _, hash := range []string{"hunter2", "$1$salt$md5hash", "$2b$04$tooshort"} {
+		_, err := auth.NewBasicAuthenticator([]string{"alice:" + hash + ":read"})
+		require.ErrorIs(t, err, auth.ErrInvalidCredentials, hash)
+	}
+}
+
+func TestMiddleware_Disabled(t *testing.T) {
+	t.Parallel()
+
+	m := auth.NewMiddleware(discard())
+	m.Disable()
+
+	h := m.Require(auth.ScopeAdmin, ok)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/devices/door1", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "anonymous", rec.Body.String())
+}
+
+func TestMiddleware_NoCredentialsRejects(t *testing.T) {
+	t.Parallel()
+
+	h := auth.NewMiddleware(discard()).Require(auth.ScopeRead, ok)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/alarm", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestNewTokenAuthenticator_InvalidEntries(t *testing.T) {
+	t.Parallel()
+
+	for _, e := range []string{"alice", "alice:token", ":token:read", "alice:token:superuser"} {
+		_, err := auth.NewTokenAuthenticator([]string{e})
+		require.ErrorIs(t, err, auth.ErrInvalidCredentials, e)
+	}
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// hashPrefixes are bcrypt versions accepted in password hashes, e.g. produced by htpasswd -B.
+var hashPrefixes = []string{"$2a$", "$2b$", "$2y$"}
+
+var errWrongPassword = errors.New("wrong username or password")
+
+type user struct {
+	id   Identity
+	hash []byte
+}
+
+// BasicAuthenticator authenticates requests with HTTP basic auth against bcrypt password hashes.
+type BasicAuthenticator struct {
+	users map[string]user
+}
+
+// NewBasicAuthenticator parses "name:hash:scope1+scope2" entries, where hash is a bcrypt hash.
+func NewBasicAuthenticator(entries []string) (*BasicAuthenticator, error) {
+	a := &BasicAuthenticator{users: make(map[string]user, len(entries))}
+
+	for _, e := range entries {
+		name, hash, scopes, err := splitCredential(e)
+		if err != nil {
+			return nil, fmt.Errorf("basic: %w", err)
+		}
+
+		if err := checkHash(hash); err != nil {
+			return nil, fmt.Errorf("basic: %s: %w", name, err)
+		}
+
+		a.users[name] = user{id: Identity{Name: name, Scopes: scopes}, hash: []byte(hash)}
+	}
+
+	return a, nil
+}
+
+// Authenticate implements Authenticator.
+func (a *BasicAuthenticator) Authenticate(r *http.Request) (Identity, bool, error) {
+	name, password, ok := r.BasicAuth()
+	if !ok {
+		return Identity{}, false, nil
+	}
+
+	u, ok := a.users[name]
+	if !ok {
+		return Identity{}, false, errWrongPassword
+	}
+
+	err := bcrypt.CompareHashAndPassword(u.hash, []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return Identity{}, false, errWrongPassword
+	}
+
+	if err != nil {
+		return Identity{}, false, fmt.Errorf("basic: %w", err)
+	}
+
+	return u.id, true, nil
+}
+
+// Challenge implements Authenticator.
+func (a *BasicAuthenticator) Challenge() string {
+	return `Basic realm="hsd"`
+}
+
+// HashPassword returns a bcrypt hash of the password.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("hash password: %w", err)
+	}
+
+	return string(hash), nil
+}
+
+func checkHash(hash string) error {
+	if !slices.ContainsFunc(hashPrefixes, func(p string) bool { return strings.HasPrefix(hash, p) }) {
+		return fmt.Errorf("%w: expected a bcrypt hash starting with %s", ErrInvalidCredentials, strings.Join(hashPrefixes, ", "))
+	}
+
+	if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+
+	return nil
+}
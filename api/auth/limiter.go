@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/SuddenGunter/hsd/notify/message"
+)
+
+type notifier interface {
+	Notify(m message.Message)
+}
+
+// LimiterOptions configure the Limiter. Zero Rate disables rate limiting, zero MaxFailures disables lockout.
+type LimiterOptions struct {
+	// Rate is the number of requests per minute a client can make on average.
+	Rate float64
+	// Burst is the number of requests a client can make at once.
+	Burst int
+	// MaxFailures is the number of authentication failures within FailureWindow that locks the client out.
+	MaxFailures   int
+	FailureWindow time.Duration
+	// Lockout is how long a client stays locked out.
+	Lockout time.Duration
+	// Now returns the current time, time.Now if nil. Tests use it to control the clock.
+	Now func() time.Time
+}
+
+// Limiter rate limits clients and locks them out after repeated authentication failures.
+// Clients are identified by their IP address.
+type Limiter struct {
+	opts     LimiterOptions
+	notifier notifier
+
+	mux       *sync.Mutex
+	clients   map[string]*client
+	lastSweep time.Time
+
+	l *slog.Logger
+}
+
+type client struct {
+	tokens   float64
+	refilled time.Time
+
+	failures    []time.Time
+	lockedUntil time.Time
+}
+
+// NewLimiter returns a new Limiter. The notifier is told about every lockout.
+func NewLimiter(opts LimiterOptions, notifier notifier, l *slog.Logger) *Limiter {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	return &Limiter{
+		opts:     opts,
+		notifier: notifier,
+		mux:      &sync.Mutex{},
+		clients:  make(map[string]*client),
+		l:        l,
+	}
+}
+
+// Allow returns false and how long to wait if the client is locked out or exceeded the rate limit.
+func (lim *Limiter) Allow(addr string) (time.Duration, bool) {
+	lim.mux.Lock()
+	defer lim.mux.Unlock()
+
+	now := lim.opts.Now()
+	lim.sweep(now)
+
+	c := lim.client(addr, now)
+
+	if now.Before(c.lockedUntil) {
+		return c.lockedUntil.Sub(now), false
+	}
+
+	if lim.opts.Rate <= 0 {
+		return 0, true
+	}
+
+	perSecond := lim.opts.Rate / 60
+	c.tokens = min(float64(lim.opts.Burst), c.tokens+now.Sub(c.refilled).Seconds()*perSecond)
+	c.refilled = now
+
+	if c.tokens < 1 {
+		return time.Duration((1 - c.tokens) / perSecond * float64(time.Second)), false
+	}
+
+	c.tokens--
+
+	return 0, true
+}
+
+// Locked returns true and the remaining lockout if the client is locked out.
+func (lim *Limiter) Locked(addr string) (time.Duration, bool) {
+	lim.mux.Lock()
+	defer lim.mux.Unlock()
+
+	now := lim.opts.Now()
+
+	c, ok := lim.clients[addr]
+	if !ok || !now.Before(c.lockedUntil) {
+		return 0, false
+	}
+
+	return c.lockedUntil.Sub(now), true
+}
+
+// Failure records an authentication failure and locks the client out if it failed too many times.
+func (lim *Limiter) Failure(addr string) {
+	if lim.opts.MaxFailures <= 0 {
+		return
+	}
+
+	lim.mux.Lock()
+
+	now := lim.opts.Now()
+	c := lim.client(addr, now)
+	c.failures = append(recent(c.failures, now.Add(-lim.opts.FailureWindow)), now)
+
+	if len(c.failures) < lim.opts.MaxFailures {
+		lim.mux.Unlock()
+		return
+	}
+
+	c.failures = nil
+	c.lockedUntil = now.Add(lim.opts.Lockout)
+	lim.mux.Unlock()
+
+	msg := message.New(message.KeyLockout, message.Warning, "api",
+		"client", addr, "lockout", lim.opts.Lockout.String(), "failures", strconv.Itoa(lim.opts.MaxFailures))
+	lim.l.Warn("api client locked out", "remote", addr, "lockout", lim.opts.Lockout)
+
+	// don't hold the request while the notification is delivered
+	go lim.notifier.Notify(msg)
+}
+
+// client returns the state of the client, creating it if needed. Must be called with lim.mux held.
+func (lim *Limiter) client(addr string, now time.Time) *client {
+	c, ok := lim.clients[addr]
+	if !ok {
+		c = &client{tokens: float64(lim.opts.Burst), refilled: now}
+		lim.clients[addr] = c
+	}
+
+	return c
+}
+
+// sweep forgets clients with a full bucket, no recent failures and no lockout. Must be called with lim.mux held.
+func (lim *Limiter) sweep(now time.Time) {
+	interval := max(lim.opts.FailureWindow, time.Minute)
+	if now.Sub(lim.lastSweep) < interval {
+		return
+	}
+
+	lim.lastSweep = now
+
+	for addr, c := range lim.clients {
+		full := lim.opts.Rate <= 0 || c.tokens+now.Sub(c.refilled).Seconds()*lim.opts.Rate/60 >= float64(lim.opts.Burst)
+		failed := len(recent(c.failures, now.Add(-lim.opts.FailureWindow))) > 0
+
+		if full && !failed && !now.Before(c.lockedUntil) {
+			delete(lim.clients, addr)
+		}
+	}
+}
+
+// recent returns failures after the cutoff. Failures are sorted, oldest first.
+func recent(failures []time.Time, cutoff time.Time) []time.Time {
+	for i, f := range failures {
+		if f.After(cutoff) {
+			return failures[i:]
+		}
+	}
+
+	return nil
+}
+
+// clientAddr returns the IP address of the client, without the port.
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,176 @@
+package auth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SuddenGunter/hsd/api/auth"
+	"github.com/SuddenGunter/hsd/notify/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	mux sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+type fakeNotifier struct {
+	mux  sync.Mutex
+	msgs []string
+}
+
+func (n *fakeNotifier) Notify(m message.Message) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	n.msgs = append(n.msgs, m.String())
+}
+
+func (n *fakeNotifier) messages() []string {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	return append([]string(nil), n.msgs...)
+}
+
+func TestLimiter_RateLimit(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Now()}
+	lim := auth.NewLimiter(auth.LimiterOptions{Rate: 60, Burst: 2, Now: clock.Now}, &fakeNotifier{}, discard())
+
+	for range 2 {
+		_, ok := lim.Allow("10.0.0.1")
+		require.True(t, ok)
+	}
+
+	retry, ok := lim.Allow("10.0.0.1")
+	require.False(t, ok)
+	assert.Equal(t, time.Second, retry)
+
+	// other clients have their own budget
+	_, ok = lim.Allow("10.0.0.2")
+	assert.True(t, ok)
+
+	clock.Advance(time.Second)
+
+	_, ok = lim.Allow("10.0.0.1")
+	assert.True(t, ok)
+}
+
+func TestMiddleware_LockoutAfterFailures(t *testing.T) {
+	t.Parallel()
+
+	a, err := auth.NewTokenAuthenticator([]string{"alice:secret:arm"})
+	require.NoError(t, err)
+
+	clock := &fakeClock{now: time.Now()}
+	n := &fakeNotifier{}
+
+	m := auth.NewMiddleware(discard(), a)
+	m.Limit(auth.NewLimiter(auth.LimiterOptions{
+		MaxFailures:   3,
+		FailureWindow: time.Minute,
+		Lockout:       10 * time.Minute,
+		Now:           clock.Now,
+	}, n, discard()))
+
+	h := m.Authenticate(ok)
+
+	do := func(method, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/alarm", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	// failures outside of the window are forgotten
+	do(http.MethodPost, "wrong")
+	do(http.MethodPost, "wrong")
+	clock.Advance(2 * time.Minute)
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "secret").Code)
+
+	for range 3 {
+		assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "wrong").Code)
+	}
+
+	rec := do(http.MethodPost, "secret")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "600", rec.Header().Get("Retry-After"))
+
+	// the lockout applies to read only requests too
+	assert.Equal(t, http.StatusTooManyRequests, do(http.MethodGet, "secret").Code)
+
+	assert.Eventually(t, func() bool { return len(n.messages()) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Contains(t, n.messages()[0], "10.0.0.1 locked out")
+
+	clock.Advance(10 * time.Minute)
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "secret").Code)
+}
+
+func TestMiddleware_ReadFailuresLockOut(t *testing.T) {
+	t.Parallel()
+
+	a, err := auth.NewTokenAuthenticator([]string{"alice:secret:read+arm"})
+	require.NoError(t, err)
+
+	clock := &fakeClock{now: time.Now()}
+
+	m := auth.NewMiddleware(discard(), a)
+	m.Limit(auth.NewLimiter(auth.LimiterOptions{
+		Rate:          60,
+		Burst:         1,
+		MaxFailures:   3,
+		FailureWindow: time.Minute,
+		Lockout:       10 * time.Minute,
+		Now:           clock.Now,
+	}, &fakeNotifier{}, discard()))
+
+	h := m.Authenticate(ok)
+
+	do := func(method, token string) int {
+		req := httptest.NewRequest(method, "/alarm", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	// read only requests are not rate limited
+	for range 3 {
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "secret"))
+	}
+
+	// but guessing tokens on them locks the client out of every endpoint
+	for range 3 {
+		assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "guess"))
+	}
+
+	assert.Equal(t, http.StatusTooManyRequests, do(http.MethodPost, "secret"))
+	assert.Equal(t, http.StatusTooManyRequests, do(http.MethodGet, "secret"))
+}
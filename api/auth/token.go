@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var errUnknownToken = errors.New("unknown bearer token")
+
+type token struct {
+	id     Identity
+	digest [sha256.Size]byte
+}
+
+// TokenAuthenticator authenticates requests with static bearer tokens.
+type TokenAuthenticator struct {
+	tokens []token
+}
+
+// NewTokenAuthenticator parses "name:token:scope1+scope2" entries.
+func NewTokenAuthenticator(entries []string) (*TokenAuthenticator, error) {
+	a := &TokenAuthenticator{}
+
+	for _, e := range entries {
+		name, secret, scopes, err := splitCredential(e)
+		if err != nil {
+			return nil, fmt.Errorf("token: %w", err)
+		}
+
+		a.tokens = append(a.tokens, token{id: Identity{Name: name, Scopes: scopes}, digest: sha256.Sum256([]byte(secret))})
+	}
+
+	return a, nil
+}
+
+// Authenticate implements Authenticator.
+func (a *TokenAuthenticator) Authenticate(r *http.Request) (Identity, bool, error) {
+	secret, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return Identity{}, false, nil
+	}
+
+	// compare digests of equal length in constant time, and check all tokens to not leak which one matched
+	digest := sha256.Sum256([]byte(secret))
+	found := -1
+
+	for i, t := range a.tokens {
+		if subtle.ConstantTimeCompare(digest[:], t.digest[:]) == 1 {
+			found = i
+		}
+	}
+
+	if found < 0 {
+		return Identity{}, false, errUnknownToken
+	}
+
+	return a.tokens[found].id, true, nil
+}
+
+// Challenge implements Authenticator.
+func (a *TokenAuthenticator) Challenge() string {
+	return `Bearer realm="hsd"`
+}
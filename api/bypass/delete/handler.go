@@ -0,0 +1,50 @@
+package bypassdeletehandler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	"github.com/SuddenGunter/hsd/api/auth"
+	"github.com/SuddenGunter/hsd/api/httpjson"
+)
+
+// DeleteHandler handles DELETE requests to /devices/{name}/bypass.
+// Ending a bypass of a device that is not bypassed is not an error.
+type DeleteHandler struct {
+	l       *slog.Logger
+	devices *alarm.DeviceMessenger
+}
+
+// NewDeleteHandler returns a new DeleteHandler.
+func NewDeleteHandler(l *slog.Logger, devices *alarm.DeviceMessenger) *DeleteHandler {
+	return &DeleteHandler{l, devices}
+}
+
+// ServeHTTP handles the request.
+func (h *DeleteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	actor := alarm.Actor{Source: alarm.SourceAPI}
+	if id, ok := auth.FromContext(r.Context()); ok {
+		actor.Name = id.Name
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	name := r.PathValue("name")
+
+	s, err := h.devices.EndBypass(ctx, name, actor)
+
+	switch {
+	case errors.Is(err, alarm.ErrDeviceNotFound):
+		httpjson.Error(w, http.StatusNotFound, "device not found")
+	case err != nil:
+		h.l.Error("failed to end device bypass", "device", name, "err", err)
+		httpjson.Error(w, http.StatusInternalServerError, "internal server error")
+	default:
+		httpjson.Write(h.l, w, r, http.StatusOK, s)
+	}
+}
@@ -0,0 +1,98 @@
+package bypassputhandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	"github.com/SuddenGunter/hsd/api/auth"
+	"github.com/SuddenGunter/hsd/api/httpjson"
+)
+
+// MaxDuration is the longest allowed bypass, longer ones should remove the device instead.
+const MaxDuration = 7 * 24 * time.Hour
+
+var errDurationRequired = errors.New("either duration or untilDisarm is required")
+
+// PutHandler handles PUT requests to /devices/{name}/bypass.
+type PutHandler struct {
+	l       *slog.Logger
+	devices *alarm.DeviceMessenger
+}
+
+// NewPutHandler returns a new PutHandler.
+func NewPutHandler(l *slog.Logger, devices *alarm.DeviceMessenger) *PutHandler {
+	return &PutHandler{l, devices}
+}
+
+// ServeHTTP handles the request.
+func (h *PutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req request
+	if !httpjson.Decode(w, r, &req) {
+		return
+	}
+
+	b := alarm.Bypass{Source: alarm.SourceAPI, Reason: req.Reason}
+	if id, ok := auth.FromContext(r.Context()); ok {
+		b.Actor = id.Name
+	}
+
+	if !req.UntilDisarm {
+		until := time.Now().Add(req.duration)
+		b.Until = &until
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	name := r.PathValue("name")
+
+	s, err := h.devices.Bypass(ctx, name, b)
+
+	switch {
+	case errors.Is(err, alarm.ErrDeviceNotFound):
+		httpjson.Error(w, http.StatusNotFound, "device not found")
+	case err != nil:
+		h.l.Error("failed to bypass device", "device", name, "err", err)
+		httpjson.Error(w, http.StatusInternalServerError, "internal server error")
+	default:
+		httpjson.Write(h.l, w, r, http.StatusOK, s)
+	}
+}
+
+type request struct {
+	// Duration of the bypass, e.g. 30m.
+	Duration    string `json:"duration"`
+	UntilDisarm bool   `json:"untilDisarm"`
+	Reason      string `json:"reason"`
+
+	duration time.Duration
+}
+
+// Validate implements httpjson.Validator.
+func (req *request) Validate() error {
+	if (req.Duration == "") == !req.UntilDisarm {
+		return errDurationRequired
+	}
+
+	if req.UntilDisarm {
+		return nil
+	}
+
+	d, err := time.ParseDuration(req.Duration)
+	if err != nil {
+		return fmt.Errorf("invalid duration: %w", err)
+	}
+
+	if d <= 0 || d > MaxDuration {
+		return fmt.Errorf("invalid duration: must be positive and at most %s", MaxDuration)
+	}
+
+	req.duration = d
+
+	return nil
+}
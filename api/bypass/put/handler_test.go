@@ -0,0 +1,87 @@
+package bypassputhandler_test
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	bypassputhandler "github.com/SuddenGunter/hsd/api/bypass/put"
+	"github.com/SuddenGunter/hsd/api/httpjson"
+	"github.com/SuddenGunter/hsd/event"
+	"github.com/SuddenGunter/hsd/notify/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type nopAlarmer struct{}
+
+func (nopAlarmer) Alarm(string, string) {}
+
+type nopNotifier struct{}
+
+func (nopNotifier) Notify(message.Message) {}
+
+type nopRecorder struct{}
+
+func (nopRecorder) Record(event.Event) {}
+
+func TestPutHandler(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		device string
+		body   string
+		status int
+		err    string
+	}{
+		{name: "duration", device: "door1", body: `{"duration":"30m","reason":"faulty"}`, status: http.StatusOK},
+		{name: "until disarm", device: "door1", body: `{"untilDisarm":true}`, status: http.StatusOK},
+		{name: "neither", device: "door1", body: `{}`, status: http.StatusBadRequest, err: "invalid request: either duration or untilDisarm is required"},
+		{name: "both", device: "door1", body: `{"duration":"30m","untilDisarm":true}`, status: http.StatusBadRequest},
+		{name: "invalid duration", device: "door1", body: `{"duration":"soon"}`, status: http.StatusBadRequest},
+		{name: "negative duration", device: "door1", body: `{"duration":"-1m"}`, status: http.StatusBadRequest},
+		{name: "too long", device: "door1", body: `{"duration":"169h"}`, status: http.StatusBadRequest},
+		{name: "unknown device", device: "door2", body: `{"duration":"30m"}`, status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			l := slog.New(slog.NewTextHandler(io.Discard, nil))
+			m := alarm.NewDeviceMessenger([]string{"door1"}, nopAlarmer{}, nopNotifier{}, nopRecorder{}, alarm.MessengerOptions{}, l)
+			m.Listen()
+			t.Cleanup(m.Close)
+
+			mux := http.NewServeMux()
+			mux.Handle("PUT /devices/{name}/bypass", bypassputhandler.NewPutHandler(l, m))
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/devices/"+tt.device+"/bypass", strings.NewReader(tt.body)))
+
+			require.Equal(t, tt.status, rec.Code, rec.Body.String())
+
+			if tt.status != http.StatusOK {
+				var resp httpjson.ErrorResponse
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+
+				if tt.err != "" {
+					assert.Equal(t, tt.err, resp.Error)
+				}
+
+				return
+			}
+
+			var s alarm.DeviceStatus
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &s))
+			require.NotNil(t, s.Bypass)
+			assert.Equal(t, alarm.SourceAPI, s.Bypass.Source)
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package devicesdeletehandler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/SuddenGunter/hsd/api/httpjson"
+	"github.com/SuddenGunter/hsd/registry"
+)
+
+// DeleteHandler handles DELETE requests to /devices/{name}.
+type DeleteHandler struct {
+	l        *slog.Logger
+	registry *registry.Registry
+}
+
+// NewDeleteHandler returns a new DeleteHandler.
+func NewDeleteHandler(l *slog.Logger, registry *registry.Registry) *DeleteHandler {
+	return &DeleteHandler{l, registry}
+}
+
+// ServeHTTP handles the request.
+func (h *DeleteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	err := h.registry.Remove(name)
+
+	switch {
+	case errors.Is(err, registry.ErrDeviceNotFound):
+		httpjson.Error(w, http.StatusNotFound, "device not found")
+	case err != nil:
+		h.l.Error("failed to remove device", "device", name, "err", err)
+		httpjson.Error(w, http.StatusInternalServerError, "internal server error")
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
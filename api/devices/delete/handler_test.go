@@ -0,0 +1,57 @@
+package devicesdeletehandler_test
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	devicesdeletehandler "github.com/SuddenGunter/hsd/api/devices/delete"
+	"github.com/SuddenGunter/hsd/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type nopModes struct{}
+
+func (nopModes) SetDeviceModes(string, []alarm.Mode) {}
+
+type fakeSet struct {
+	removed []string
+}
+
+func (*fakeSet) AddDevice(string)           {}
+func (s *fakeSet) RemoveDevice(name string) { s.removed = append(s.removed, name) }
+
+func TestDeleteHandler(t *testing.T) {
+	t.Parallel()
+
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+	path := filepath.Join(t.TempDir(), "devices.json")
+
+	reg, err := registry.Open(path, []alarm.DeviceConfig{{Name: "door1"}, {Name: "door2"}}, l)
+	require.NoError(t, err)
+
+	set := &fakeSet{}
+	reg.Attach(nopModes{}, set)
+
+	mux := http.NewServeMux()
+	mux.Handle("DELETE /devices/{name}", devicesdeletehandler.NewDeleteHandler(l, reg))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/devices/door1", nil))
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+	assert.Equal(t, []string{"door1"}, set.removed)
+
+	// the change is persisted while the config is unchanged
+	reopened, err := registry.Open(path, []alarm.DeviceConfig{{Name: "door1"}, {Name: "door2"}}, l)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"door2"}, reopened.Names())
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/devices/door1", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,55 @@
+package devicesgethandler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	"github.com/SuddenGunter/hsd/api/httpjson"
+)
+
+// GetHandler handles GET requests to /devices and /devices/{name}.
+type GetHandler struct {
+	l       *slog.Logger
+	devices *alarm.DeviceMessenger
+}
+
+// NewGetHandler returns a new GetHandler.
+func NewGetHandler(l *slog.Logger, devices *alarm.DeviceMessenger) *GetHandler {
+	return &GetHandler{l, devices}
+}
+
+// ServeHTTP handles the request.
+func (h *GetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	var (
+		v   any
+		err error
+	)
+
+	if name := r.PathValue("name"); name != "" {
+		v, err = h.devices.Status(ctx, name)
+	} else {
+		v, err = h.devices.Statuses(ctx)
+	}
+
+	if errors.Is(err, alarm.ErrDeviceNotFound) {
+		httpjson.Error(w, http.StatusNotFound, "device not found")
+
+		return
+	}
+
+	if err != nil {
+		h.l.Error("failed to get device status", "err", err)
+		httpjson.Error(w, http.StatusInternalServerError, "internal server error")
+
+		return
+	}
+
+	httpjson.Write(h.l, w, r, http.StatusOK, v)
+}
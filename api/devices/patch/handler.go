@@ -0,0 +1,80 @@
+package devicespatchhandler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	"github.com/SuddenGunter/hsd/api/httpjson"
+	"github.com/SuddenGunter/hsd/registry"
+)
+
+var errNothingToChange = errors.New("at least one of modes, label and location is required")
+
+// PatchHandler handles PATCH requests to /devices/{name}.
+type PatchHandler struct {
+	l        *slog.Logger
+	registry *registry.Registry
+}
+
+// NewPatchHandler returns a new PatchHandler.
+func NewPatchHandler(l *slog.Logger, registry *registry.Registry) *PatchHandler {
+	return &PatchHandler{l, registry}
+}
+
+// ServeHTTP handles the request.
+func (h *PatchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req request
+	if !httpjson.Decode(w, r, &req) {
+		return
+	}
+
+	d, ok := h.registry.Device(r.PathValue("name"))
+	if !ok {
+		httpjson.Error(w, http.StatusNotFound, "device not found")
+		return
+	}
+
+	// omitted fields keep their values
+	if req.Modes != nil {
+		d.Modes = *req.Modes
+	}
+
+	if req.Label != nil {
+		d.Label = *req.Label
+	}
+
+	if req.Location != nil {
+		d.Location = *req.Location
+	}
+
+	err := h.registry.Update(d)
+
+	switch {
+	case errors.Is(err, registry.ErrInvalidDevice):
+		httpjson.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, registry.ErrDeviceNotFound):
+		httpjson.Error(w, http.StatusNotFound, "device not found")
+	case err != nil:
+		h.l.Error("failed to update device", "device", d.Name, "err", err)
+		httpjson.Error(w, http.StatusInternalServerError, "internal server error")
+	default:
+		httpjson.Write(h.l, w, r, http.StatusOK, d)
+	}
+}
+
+type request struct {
+	Modes    *[]alarm.Mode `json:"modes"`
+	Label    *string       `json:"label"`
+	Location *string       `json:"location"`
+}
+
+// Validate implements httpjson.Validator.
+func (req *request) Validate() error {
+	if req.Modes == nil && req.Label == nil && req.Location == nil {
+		return errNothingToChange
+	}
+
+	return nil
+}
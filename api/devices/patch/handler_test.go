@@ -0,0 +1,101 @@
+package devicespatchhandler_test
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	devicespatchhandler "github.com/SuddenGunter/hsd/api/devices/patch"
+	"github.com/SuddenGunter/hsd/api/httpjson"
+	"github.com/SuddenGunter/hsd/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type nopModes struct{}
+
+func (nopModes) SetDeviceModes(string, []alarm.Mode) {}
+
+func TestPatchHandler(t *testing.T) {
+	t.Parallel()
+
+	door1 := alarm.DeviceConfig{Name: "door1", Modes: []alarm.Mode{alarm.ModeAway}, Label: "Front door"}
+
+	tests := []struct {
+		name   string
+		device string
+		body   string
+		status int
+		want   alarm.DeviceConfig
+		err    string
+	}{
+		{name: "modes", device: "door1", body: `{"modes":["away","night"]}`, status: http.StatusOK,
+			want: alarm.DeviceConfig{Name: "door1", Modes: []alarm.Mode{alarm.ModeAway, alarm.ModeNight}, Label: "Front door"}},
+		{name: "all modes", device: "door1", body: `{"modes":[]}`, status: http.StatusOK,
+			want: alarm.DeviceConfig{Name: "door1", Modes: []alarm.Mode{}, Label: "Front door"}},
+		{name: "location keeps the rest", device: "door1", body: `{"location":"hallway"}`, status: http.StatusOK,
+			want: alarm.DeviceConfig{Name: "door1", Modes: []alarm.Mode{alarm.ModeAway}, Label: "Front door", Location: "hallway"}},
+		{name: "clear label", device: "door1", body: `{"label":""}`, status: http.StatusOK,
+			want: alarm.DeviceConfig{Name: "door1", Modes: []alarm.Mode{alarm.ModeAway}}},
+		{name: "nothing to change", device: "door1", body: `{}`, status: http.StatusBadRequest,
+			err: "invalid request: at least one of modes, label and location is required"},
+		{name: "unknown mode", device: "door1", body: `{"modes":["vacation"]}`, status: http.StatusBadRequest},
+		{name: "unknown device", device: "door2", body: `{"label":"Back door"}`, status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			l := slog.New(slog.NewTextHandler(io.Discard, nil))
+			path := filepath.Join(t.TempDir(), "devices.json")
+
+			reg, err := registry.Open(path, []alarm.DeviceConfig{door1}, l)
+			require.NoError(t, err)
+			reg.Attach(nopModes{})
+
+			mux := http.NewServeMux()
+			mux.Handle("PATCH /devices/{name}", devicespatchhandler.NewPatchHandler(l, reg))
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/devices/"+tt.device, strings.NewReader(tt.body)))
+
+			require.Equal(t, tt.status, rec.Code, rec.Body.String())
+
+			if tt.status != http.StatusOK {
+				var resp httpjson.ErrorResponse
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+
+				if tt.err != "" {
+					assert.Equal(t, tt.err, resp.Error)
+				}
+
+				d, _ := reg.Device("door1")
+				assert.Equal(t, door1, d)
+
+				return
+			}
+
+			var d alarm.DeviceConfig
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &d))
+			assert.Equal(t, tt.want.Label, d.Label)
+			assert.Equal(t, tt.want.Location, d.Location)
+
+			// the change is persisted while the config is unchanged
+			reopened, err := registry.Open(path, []alarm.DeviceConfig{door1}, l)
+			require.NoError(t, err)
+
+			saved, ok := reopened.Device("door1")
+			require.True(t, ok)
+			assert.ElementsMatch(t, tt.want.Modes, saved.Modes)
+			assert.Equal(t, tt.want.Label, saved.Label)
+			assert.Equal(t, tt.want.Location, saved.Location)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package devicesposthandler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	"github.com/SuddenGunter/hsd/api/httpjson"
+	"github.com/SuddenGunter/hsd/registry"
+)
+
+// PostHandler handles POST requests to /devices.
+type PostHandler struct {
+	l        *slog.Logger
+	registry *registry.Registry
+}
+
+// NewPostHandler returns a new PostHandler.
+func NewPostHandler(l *slog.Logger, registry *registry.Registry) *PostHandler {
+	return &PostHandler{l, registry}
+}
+
+// ServeHTTP handles the request.
+func (h *PostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req alarm.DeviceConfig
+	if !httpjson.Decode(w, r, &req) {
+		return
+	}
+
+	err := h.registry.Add(req)
+
+	switch {
+	case errors.Is(err, registry.ErrInvalidDevice):
+		httpjson.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, registry.ErrDeviceExists):
+		httpjson.Error(w, http.StatusConflict, err.Error())
+	case err != nil:
+		h.l.Error("failed to add device", "device", req.Name, "err", err)
+		httpjson.Error(w, http.StatusInternalServerError, "internal server error")
+	default:
+		httpjson.Write(h.l, w, r, http.StatusCreated, req)
+	}
+}
@@ -0,0 +1,84 @@
+package devicesposthandler_test
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	devicesposthandler "github.com/SuddenGunter/hsd/api/devices/post"
+	"github.com/SuddenGunter/hsd/api/httpjson"
+	"github.com/SuddenGunter/hsd/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type nopModes struct{}
+
+func (nopModes) SetDeviceModes(string, []alarm.Mode) {}
+
+func TestPostHandler(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		err    string
+	}{
+		{name: "all modes", body: `{"name":"door2"}`, status: http.StatusCreated},
+		{name: "with modes and label", body: `{"name":"door2","modes":["away"],"label":"Back door"}`, status: http.StatusCreated},
+		{name: "no name", body: `{"modes":["away"]}`, status: http.StatusBadRequest, err: "invalid device: name is required"},
+		{name: "topic characters", body: `{"name":"door/2"}`, status: http.StatusBadRequest},
+		{name: "unknown mode", body: `{"name":"door2","modes":["vacation"]}`, status: http.StatusBadRequest, err: `invalid device: unknown mode "vacation"`},
+		{name: "unknown field", body: `{"name":"door2","zone":"garage"}`, status: http.StatusBadRequest},
+		{name: "duplicate", body: `{"name":"door1"}`, status: http.StatusConflict, err: "device already exists: door1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			l := slog.New(slog.NewTextHandler(io.Discard, nil))
+			path := filepath.Join(t.TempDir(), "devices.json")
+
+			reg, err := registry.Open(path, []alarm.DeviceConfig{{Name: "door1"}}, l)
+			require.NoError(t, err)
+			reg.Attach(nopModes{})
+
+			rec := httptest.NewRecorder()
+			devicesposthandler.NewPostHandler(l, reg).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/devices", strings.NewReader(tt.body)))
+
+			require.Equal(t, tt.status, rec.Code, rec.Body.String())
+
+			if tt.status != http.StatusCreated {
+				var resp httpjson.ErrorResponse
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+
+				if tt.err != "" {
+					assert.Equal(t, tt.err, resp.Error)
+				}
+
+				assert.Equal(t, []string{"door1"}, reg.Names())
+
+				return
+			}
+
+			var d alarm.DeviceConfig
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &d))
+
+			// the device is persisted
+			reopened, err := registry.Open(path, []alarm.DeviceConfig{{Name: "door1"}}, l)
+			require.NoError(t, err)
+
+			saved, ok := reopened.Device("door2")
+			require.True(t, ok)
+			assert.Equal(t, d, saved)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package eventsgethandler
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/SuddenGunter/hsd/api/httpjson"
+	"github.com/SuddenGunter/hsd/event"
+)
+
+// GetHandler handles GET requests to /events.
+type GetHandler struct {
+	l     *slog.Logger
+	store *event.Store
+}
+
+// NewGetHandler returns a new GetHandler.
+func NewGetHandler(l *slog.Logger, store *event.Store) *GetHandler {
+	return &GetHandler{l, store}
+}
+
+// ServeHTTP handles the request.
+// Supported query parameters: device, type, since and until (RFC 3339), before (event id) and limit.
+func (h *GetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilter(r.URL.Query())
+	if err != nil {
+		httpjson.Error(w, http.StatusBadRequest, err.Error())
+
+		return
+	}
+
+	httpjson.Write(h.l, w, r, http.StatusOK, h.store.Query(f))
+}
+
+func parseFilter(q url.Values) (event.Filter, error) {
+	f := event.Filter{
+		Device: q.Get("device"),
+		Type:   event.Type(q.Get("type")),
+		Limit:  100,
+	}
+
+	var err error
+
+	if v := q.Get("since"); v != "" {
+		if f.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, fmt.Errorf("invalid since: %w", err)
+		}
+	}
+
+	if v := q.Get("until"); v != "" {
+		if f.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, fmt.Errorf("invalid until: %w", err)
+		}
+	}
+
+	if v := q.Get("before"); v != "" {
+		if f.Before, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return f, fmt.Errorf("invalid before: %w", err)
+		}
+	}
+
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 1 || f.Limit > event.MaxLimit {
+			return f, fmt.Errorf("invalid limit: must be between 1 and %d", event.MaxLimit)
+		}
+	}
+
+	return f, nil
+}
@@ -0,0 +1,132 @@
+package eventsstreamhandler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SuddenGunter/hsd/api/httpjson"
+	"github.com/SuddenGunter/hsd/event"
+)
+
+const (
+	heartbeatInterval = 15 * time.Second
+	clientBuffer      = 64
+)
+
+// StreamHandler handles GET requests to /events/stream.
+// It streams live events as Server-Sent Events, optionally filtered by device and type query parameters.
+// Clients reconnecting with Last-Event-ID receive events they missed first.
+type StreamHandler struct {
+	l     *slog.Logger
+	store *event.Store
+	bus   *event.Bus
+
+	done chan struct{}
+}
+
+// NewStreamHandler returns a new StreamHandler.
+func NewStreamHandler(l *slog.Logger, store *event.Store, bus *event.Bus) *StreamHandler {
+	return &StreamHandler{l: l, store: store, bus: bus, done: make(chan struct{})}
+}
+
+// Close disconnects all clients. It is meant to be registered with http.Server.RegisterOnShutdown,
+// as Shutdown does not interrupt active requests.
+func (h *StreamHandler) Close() {
+	close(h.done)
+}
+
+// ServeHTTP handles the request.
+func (h *StreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f := event.Filter{Device: r.URL.Query().Get("device"), Type: event.Type(r.URL.Query().Get("type"))}
+
+	var lastID uint64
+
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			httpjson.Error(w, http.StatusBadRequest, "invalid Last-Event-ID")
+
+			return
+		}
+
+		lastID = id
+	}
+
+	rc := http.NewResponseController(w)
+
+	// subscribe before replaying missed events, so nothing is lost in between
+	sub := h.bus.Subscribe(clientBuffer)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	if lastID != 0 {
+		for _, e := range h.store.After(lastID, f) {
+			if !h.write(w, r, e) {
+				return
+			}
+
+			lastID = e.ID
+		}
+	}
+
+	if err := rc.Flush(); err != nil {
+		h.l.Warn("failed to flush event stream", "err", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.done:
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case e, ok := <-sub.C():
+			if !ok {
+				// the client fell behind, it will reconnect with Last-Event-ID
+				return
+			}
+
+			if e.ID <= lastID || !f.Match(e) {
+				continue
+			}
+
+			if !h.write(w, r, e) {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (h *StreamHandler) write(w http.ResponseWriter, r *http.Request, e event.Event) bool {
+	data, err := json.Marshal(e)
+	if err != nil {
+		h.l.Error("failed to marshal event", "err", err, "id", e.ID)
+		return false
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	if err != nil {
+		h.l.Debug("event stream client disconnected", "err", err, "path", r.URL.Path)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,102 @@
+package healthhandler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/SuddenGunter/hsd/api/httpjson"
+)
+
+const checkTimeout = 2 * time.Second
+
+// Check reports the state of a dependency. It returns a human readable detail, and an error if the dependency is not ready.
+type Check func(ctx context.Context) (string, error)
+
+// Status values of the response and of each check.
+const (
+	statusOK          = "ok"
+	statusUnavailable = "unavailable"
+)
+
+type checkResult struct {
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type response struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks,omitempty"`
+}
+
+// NewLiveHandler returns a handler for GET /healthz. It responds 200 as long as the process is serving requests.
+func NewLiveHandler(l *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		write(l, w, r, http.StatusOK, response{Status: statusOK})
+	})
+}
+
+// ReadyHandler handles GET requests to /readyz.
+// It runs all checks concurrently and responds 503 if any of them fails.
+type ReadyHandler struct {
+	l      *slog.Logger
+	checks map[string]Check
+}
+
+// NewReadyHandler returns a new ReadyHandler.
+func NewReadyHandler(l *slog.Logger, checks map[string]Check) *ReadyHandler {
+	return &ReadyHandler{l, checks}
+}
+
+// ServeHTTP handles the request.
+func (h *ReadyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+	defer cancel()
+
+	resp := response{Status: statusOK, Checks: make(map[string]checkResult, len(h.checks))}
+	mux := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+
+	for name, check := range h.checks {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			detail, err := check(ctx)
+			res := checkResult{Status: statusOK, Detail: detail}
+
+			if err != nil {
+				res.Status = statusUnavailable
+				res.Error = err.Error()
+			}
+
+			mux.Lock()
+			defer mux.Unlock()
+
+			resp.Checks[name] = res
+			if err != nil {
+				resp.Status = statusUnavailable
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	status := http.StatusOK
+	if resp.Status != statusOK {
+		status = http.StatusServiceUnavailable
+
+		h.l.Warn("readiness check failed", "checks", resp.Checks)
+	}
+
+	write(h.l, w, r, status, resp)
+}
+
+func write(l *slog.Logger, w http.ResponseWriter, r *http.Request, status int, resp response) {
+	w.Header().Set("Cache-Control", "no-store")
+	httpjson.Write(l, w, r, status, resp)
+}
@@ -0,0 +1,57 @@
+package healthhandler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	healthhandler "github.com/SuddenGunter/hsd/api/health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadyHandler(t *testing.T) {
+	t.Parallel()
+
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ok := func(context.Context) (string, error) { return "fine", nil }
+	failing := func(context.Context) (string, error) { return "", errors.New("broken") }
+
+	tests := []struct {
+		name   string
+		checks map[string]healthhandler.Check
+		status int
+		body   string
+	}{
+		{
+			name:   "all checks pass",
+			checks: map[string]healthhandler.Check{"mqtt": ok, "devices": ok},
+			status: http.StatusOK,
+			body:   `{"status":"ok","checks":{"devices":{"status":"ok","detail":"fine"},"mqtt":{"status":"ok","detail":"fine"}}}`,
+		},
+		{
+			name:   "one check fails",
+			checks: map[string]healthhandler.Check{"mqtt": failing, "devices": ok},
+			status: http.StatusServiceUnavailable,
+			body:   `{"status":"unavailable","checks":{"devices":{"status":"ok","detail":"fine"},"mqtt":{"status":"unavailable","error":"broken"}}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			w := httptest.NewRecorder()
+			healthhandler.NewReadyHandler(l, tt.checks).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			assert.Equal(t, tt.status, w.Code)
+			require.True(t, json.Valid(w.Body.Bytes()))
+			assert.JSONEq(t, tt.body, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,109 @@
+// Package httpjson implements request decoding and response encoding shared by API handlers.
+// All API errors are written as {"status": 400, "error": "message"}.
+package httpjson
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// MaxBodySize is the maximum size of a request body in bytes.
+const MaxBodySize = 64 << 10
+
+// ErrInvalidRequest is returned by Decode when the request body is malformed or fails validation.
+var ErrInvalidRequest = errors.New("invalid request")
+
+// ErrorResponse is the body of all API error responses.
+type ErrorResponse struct {
+	Status int    `json:"status"`
+	Error  string `json:"error"`
+}
+
+// Validator is implemented by request types that check their fields after decoding.
+type Validator interface {
+	Validate() error
+}
+
+// Decode decodes the JSON request body into v, rejecting unknown fields, trailing data and bodies over MaxBodySize.
+// If v implements Validator, it is validated too. On error, a 400 or 413 response is written and false returned.
+func Decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := decode(w, r, v)
+	if err == nil {
+		return true
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		Error(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body must not exceed %d bytes", maxBytesErr.Limit))
+
+		return false
+	}
+
+	Error(w, http.StatusBadRequest, err.Error())
+
+	return false
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxBodySize))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return err
+		}
+
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("%w: empty body", ErrInvalidRequest)
+		}
+
+		return fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+	}
+
+	if dec.More() {
+		return fmt.Errorf("%w: unexpected data after JSON body", ErrInvalidRequest)
+	}
+
+	if val, ok := v.(Validator); ok {
+		if err := val.Validate(); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+		}
+	}
+
+	return nil
+}
+
+// Write writes v as a JSON response with the status code.
+func Write(l *slog.Logger, w http.ResponseWriter, r *http.Request, status int, v any) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		l.Error("failed to marshal response", "err", err)
+		Error(w, http.StatusInternalServerError, "internal server error")
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_, err = w.Write(resp)
+	if err != nil {
+		l.Warn("failed to write response", "err", err, "path", r.URL.Path)
+	}
+}
+
+// Error writes a JSON error response.
+func Error(w http.ResponseWriter, status int, msg string) {
+	// marshaling a struct of a string and an int can't fail
+	resp, _ := json.Marshal(ErrorResponse{Status: status, Error: msg})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_, _ = w.Write(resp)
+}
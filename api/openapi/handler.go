@@ -0,0 +1,31 @@
+package openapihandler
+
+import (
+	_ "embed"
+	"log/slog"
+	"net/http"
+)
+
+// Spec is the OpenAPI document describing the hsd API. Keep it in sync with routes registered in app.Run.
+//
+//go:embed openapi.json
+var Spec []byte
+
+// Handler handles GET requests to /openapi.json.
+type Handler struct {
+	l *slog.Logger
+}
+
+// NewHandler returns a new Handler.
+func NewHandler(l *slog.Logger) *Handler {
+	return &Handler{l}
+}
+
+// ServeHTTP handles the request.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, err := w.Write(Spec); err != nil {
+		h.l.Warn("failed to write response", "err", err, "path", r.URL.Path)
+	}
+}
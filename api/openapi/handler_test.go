@@ -0,0 +1,60 @@
+package openapihandler_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	openapihandler "github.com/SuddenGunter/hsd/api/openapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpec_RefsResolve(t *testing.T) {
+	t.Parallel()
+
+	var spec map[string]any
+	require.NoError(t, json.Unmarshal(openapihandler.Spec, &spec))
+
+	var refs []string
+
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			for k, child := range v {
+				if ref, ok := child.(string); ok && k == "$ref" {
+					refs = append(refs, ref)
+				}
+
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+
+	walk(spec)
+	require.NotEmpty(t, refs)
+
+	for _, ref := range refs {
+		var node any = spec
+
+		for part := range strings.SplitSeq(strings.TrimPrefix(ref, "#/"), "/") {
+			m, ok := node.(map[string]any)
+			require.True(t, ok, ref)
+
+			node, ok = m[part]
+			require.True(t, ok, ref)
+		}
+	}
+
+	paths, ok := spec["paths"].(map[string]any)
+	require.True(t, ok)
+
+	for _, p := range []string{"/alarm", "/events", "/events/stream", "/devices", "/devices/{name}", "/metrics", "/healthz", "/readyz", "/openapi.json"} {
+		assert.Contains(t, paths, p)
+	}
+}
@@ -11,8 +11,27 @@ import (
 	"github.com/SuddenGunter/hsd/alarm"
 	alarmgethandler "github.com/SuddenGunter/hsd/api/alarm/get"
 	alarmposthandler "github.com/SuddenGunter/hsd/api/alarm/post"
+	"github.com/SuddenGunter/hsd/api/auth"
+	bypassdeletehandler "github.com/SuddenGunter/hsd/api/bypass/delete"
+	bypassputhandler "github.com/SuddenGunter/hsd/api/bypass/put"
+	devicesdeletehandler "github.com/SuddenGunter/hsd/api/devices/delete"
+	devicesgethandler "github.com/SuddenGunter/hsd/api/devices/get"
+	devicespatchhandler "github.com/SuddenGunter/hsd/api/devices/patch"
+	devicesposthandler "github.com/SuddenGunter/hsd/api/devices/post"
+	eventsgethandler "github.com/SuddenGunter/hsd/api/events/get"
+	eventsstreamhandler "github.com/SuddenGunter/hsd/api/events/stream"
+	healthhandler "github.com/SuddenGunter/hsd/api/health"
+	openapihandler "github.com/SuddenGunter/hsd/api/openapi"
 	"github.com/SuddenGunter/hsd/app/config"
+	"github.com/SuddenGunter/hsd/audit"
+	"github.com/SuddenGunter/hsd/certs"
+	"github.com/SuddenGunter/hsd/event"
+	"github.com/SuddenGunter/hsd/hass"
+	"github.com/SuddenGunter/hsd/notify"
+	"github.com/SuddenGunter/hsd/notify/message"
+	"github.com/SuddenGunter/hsd/registry"
 	"github.com/SuddenGunter/hsd/telegram"
+	"github.com/SuddenGunter/hsd/web"
 	"github.com/SuddenGunter/hsd/z2m"
 	"github.com/SuddenGunter/hsd/z2m/device"
 	"github.com/SuddenGunter/hsd/z2m/mqttc"
@@ -32,24 +51,82 @@ func New(l *slog.Logger, cfg *config.Config) *App {
 
 // Run starts the app and blocks until shutdown.
 func (app *App) Run(sigCtx context.Context) {
-	notifier, err := telegram.NewNotifier(app.cfg.Telegram.BotToken, app.cfg.Telegram.ChatID, app.l)
+	events, err := event.Open(app.cfg.Events.Path, app.cfg.Events.Retention, app.cfg.Events.MaxEvents, app.l)
+	if err != nil {
+		app.l.Error("failed to open event store", "err", err)
+		return
+	}
+
+	defer func() {
+		if err := events.Close(); err != nil {
+			app.l.Error("failed to close event store", "err", err)
+		}
+	}()
+
+	devices, err := registry.Open(app.cfg.DevicesPath, app.cfg.DeviceConfigs(), app.l)
+	if err != nil {
+		app.l.Error("failed to load devices", "err", err)
+		return
+	}
+
+	// notifications name devices by their labels and locations
+	telegramOptions, err := TelegramOptions(app.cfg, func(name string) (string, string) {
+		d, _ := devices.Device(name)
+		return d.Label, d.Location
+	})
+	if err != nil {
+		app.l.Error("failed to configure notification messages", "err", err)
+		return
+	}
+
+	notifier, err := telegram.NewNotifier(app.cfg.Telegram.BotToken, app.cfg.Telegram.ChatID, telegramOptions, app.l)
 	if err != nil {
 		app.l.Error("failed to create telegram notifier", "err", err)
 		return
 	}
 
-	alarmer := alarm.New(notifier, app.l)
-	devMsg := alarm.NewDeviceMessenger(app.cfg.Z2MDevices, alarmer, app.l)
+	auditLog, err := audit.Open(app.cfg.Audit.Path, app.l)
+	if err != nil {
+		app.l.Error("failed to open audit log", "err", err)
+		return
+	}
 
-	devMsg.Listen()
-	defer devMsg.Close()
+	defer func() {
+		if err := auditLog.Close(); err != nil {
+			app.l.Error("failed to close audit log", "err", err)
+		}
+	}()
 
-	gh := alarmgethandler.NewGetHandler(app.l, alarmer)
-	ph := alarmposthandler.NewPostHandler(app.l, alarmer)
+	bus := event.NewBus(events)
+	defer bus.Close()
 
-	mux := http.NewServeMux()
-	mux.Handle("GET /alarm", gh)
-	mux.Handle("POST /alarm", ph)
+	outbox, err := notify.OpenOutbox(notifier, notify.OutboxOptions{
+		Path:       app.cfg.Notify.OutboxPath,
+		MinBackoff: app.cfg.Notify.RetryMin,
+		MaxBackoff: app.cfg.Notify.RetryMax,
+		LateAfter:  app.cfg.Notify.LateAfter,
+	}, app.l)
+	if err != nil {
+		app.l.Error("failed to open notification outbox", "err", err)
+		return
+	}
+	defer app.closeOutbox(outbox)
+
+	batcher := notify.NewBatcher(outbox, notify.BatcherOptions{Window: app.cfg.Notify.BatchWindow}, app.l)
+	defer batcher.Close()
+
+	dispatcher := notify.NewDispatcher(batcher, notify.DispatcherOptions{
+		Workers:        app.cfg.Notify.Workers,
+		QueueSize:      app.cfg.Notify.QueueSize,
+		EnqueueTimeout: app.cfg.Notify.EnqueueTimeout,
+	}, app.l)
+	defer app.drain(dispatcher)
+
+	alarmer := alarm.New(dispatcher, bus, auditLog, app.alarmOptions(devices), app.l)
+	devMsg := alarm.NewDeviceMessenger(devices.Names(), alarmer, dispatcher, bus, app.messengerOptions(), app.l)
+	devMsg.ObserveAlarm(alarmer)
+	alarmer.CheckReadiness(devMsg)
+	deviceSets := []registry.DeviceSet{devMsg}
 
 	app.l.Debug("connecting to mqtt broker")
 
@@ -62,24 +139,114 @@ func (app *App) Run(sigCtx context.Context) {
 	//nolint:gosec // false positive - this code is only used on 64-bit systems
 	defer mc.Disconnect(uint((5 * time.Second).Milliseconds()))
 
-	z2ml := z2m.NewZigbee2MQTTListener(mc, device.NewDataHandler(devMsg, app.l), device.NewAvailabilityHandler(devMsg, app.l), app.cfg.Z2MDevices, app.l)
+	if app.cfg.HomeAssistant.Enabled {
+		bridge := hass.NewBridge(mc, alarmer, devices.Names(), app.cfg.HomeAssistant.DiscoveryPrefix, app.cfg.HomeAssistant.TopicPrefix, app.l)
+		alarmer.Observe(bridge)
+		devMsg.Observe(bridge)
+
+		deviceSets = append(deviceSets, bridge)
+
+		bridge.Start()
+		defer bridge.Close()
+	}
+
+	devMsg.Listen()
+	defer devMsg.Close()
+
+	z2ml := z2m.NewZigbee2MQTTListener(mc, device.NewDataHandler(devMsg, app.l), device.NewAvailabilityHandler(devMsg, app.l), devices.Names(), app.l)
+
+	devices.Attach(alarmer, append(deviceSets, z2ml)...)
+
+	reg := app.newMetrics(alarmer, devMsg, dispatcher, outbox)
+
+	gh := alarmgethandler.NewGetHandler(app.l, alarmer)
+	ph := alarmposthandler.NewPostHandler(app.l, alarmer)
+	egh := eventsgethandler.NewGetHandler(app.l, events)
+	esh := eventsstreamhandler.NewStreamHandler(app.l, events, bus)
+	dgh := devicesgethandler.NewGetHandler(app.l, devMsg)
+	dph := devicesposthandler.NewPostHandler(app.l, devices)
+	dpah := devicespatchhandler.NewPatchHandler(app.l, devices)
+	ddh := devicesdeletehandler.NewDeleteHandler(app.l, devices)
+	bph := bypassputhandler.NewPutHandler(app.l, devMsg)
+	bdh := bypassdeletehandler.NewDeleteHandler(app.l, devMsg)
+	rh := healthhandler.NewReadyHandler(app.l, app.readinessChecks(mc, z2ml, notifier, devMsg))
+
+	am, err := app.authMiddleware(dispatcher)
+	if err != nil {
+		app.l.Error("failed to configure api authentication", "err", err)
+		return
+	}
+
+	mux := http.NewServeMux()
+	// the dashboard is public, it authenticates its own API calls
+	mux.Handle("GET /", web.Handler())
+	mux.Handle("GET /alarm", am.Require(auth.ScopeRead, gh))
+	// arm and disarm scopes are checked by the handler
+	mux.Handle("POST /alarm", am.Authenticate(ph))
+	mux.Handle("GET /events", am.Require(auth.ScopeRead, egh))
+	mux.Handle("GET /events/stream", am.Require(auth.ScopeRead, esh))
+	mux.Handle("GET /devices", am.Require(auth.ScopeRead, dgh))
+	mux.Handle("GET /devices/{name}", am.Require(auth.ScopeRead, dgh))
+	mux.Handle("POST /devices", am.Require(auth.ScopeAdmin, dph))
+	mux.Handle("PATCH /devices/{name}", am.Require(auth.ScopeAdmin, dpah))
+	mux.Handle("DELETE /devices/{name}", am.Require(auth.ScopeAdmin, ddh))
+	// bypassing weakens the alarm just like disarming, reinstating strengthens it like arming
+	mux.Handle("PUT /devices/{name}/bypass", am.Require(auth.ScopeDisarm, bph))
+	mux.Handle("DELETE /devices/{name}/bypass", am.Require(auth.ScopeArm, bdh))
+	// health endpoints and the api spec are public, so orchestrators can probe them without credentials
+	mux.Handle("GET /healthz", healthhandler.NewLiveHandler(app.l))
+	mux.Handle("GET /readyz", rh)
+	mux.Handle("GET /openapi.json", openapihandler.NewHandler(app.l))
+	mux.Handle("GET /metrics", am.Require(auth.ScopeRead, reg.Handler(app.l)))
+
 	z2ml.Subscribe()
 
 	ctx, crash := context.WithCancel(sigCtx)
-	srv := http.Server{
+	defer crash()
+
+	go app.onSIGHUP(ctx, app.reloadConfig(devices, notifier))
+
+	if app.cfg.Notify.DigestAt != "" {
+		go notify.NewDigest(outbox, events, devMsg, app.digestOptions(), app.l).Run(ctx)
+	}
+
+	srv := &http.Server{
 		ReadTimeout: 5 * time.Second,
 		Addr:        fmt.Sprintf(":%d", app.cfg.Port),
 		Handler:     mux,
 	}
+	srv.RegisterOnShutdown(esh.Close)
 
-	go func() {
-		app.l.Debug("starting http server", "addr", srv.Addr)
+	servers := []*http.Server{srv}
 
-		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			app.l.Error("failed to listen and serve", "err", err)
-			crash()
+	if app.cfg.TLS.Enabled() {
+		reloader, err := certs.NewReloader(app.cfg.TLS.CertFile, app.cfg.TLS.KeyFile, app.cfg.TLS.ReloadInterval, app.l)
+		if err != nil {
+			app.l.Error("failed to load tls certificate", "err", err)
+			return
+		}
+		defer reloader.Close()
+
+		srv.TLSConfig, err = app.tlsConfig(reloader)
+		if err != nil {
+			app.l.Error("failed to configure tls", "err", err)
+			return
 		}
-	}()
+
+		go app.onSIGHUP(ctx, func() {
+			if err := reloader.Reload(); err != nil {
+				app.l.Error("failed to reload tls certificate", "err", err)
+			}
+		})
+
+		if app.cfg.TLS.RedirectPort != 0 {
+			servers = append(servers, app.redirectServer())
+		}
+	}
+
+	for _, s := range servers {
+		go app.serve(s, crash)
+	}
 
 	app.l.Info("app started")
 	<-ctx.Done()
@@ -89,9 +256,138 @@ func (app *App) Run(sigCtx context.Context) {
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
-	if err := srv.Shutdown(shutdownCtx); err != nil {
-		app.l.Info("server shutdown returned an err: %v\n", "err", err)
+	for _, s := range servers {
+		if err := s.Shutdown(shutdownCtx); err != nil {
+			app.l.Info("server shutdown returned an err: %v\n", "err", err)
+		}
 	}
 
 	app.l.Info("shutdown complete")
 }
+
+var (
+	errNoCredentials = errors.New("no api credentials configured, set AUTH_TOKENS, AUTH_USERS or TLS_CLIENT_CA_FILE, or AUTH_DISABLED=true")
+	errAuthConflict  = errors.New("AUTH_DISABLED is set together with api credentials")
+)
+
+func (app *App) authMiddleware(notifier *notify.Dispatcher) (*auth.Middleware, error) {
+	var authenticators []auth.Authenticator
+
+	if len(app.cfg.Auth.Tokens) > 0 {
+		a, err := auth.NewTokenAuthenticator(app.cfg.Auth.Tokens)
+		if err != nil {
+			return nil, fmt.Errorf("auth: %w", err)
+		}
+
+		authenticators = append(authenticators, a)
+	}
+
+	if len(app.cfg.Auth.Users) > 0 {
+		a, err := auth.NewBasicAuthenticator(app.cfg.Auth.Users)
+		if err != nil {
+			return nil, fmt.Errorf("auth: %w", err)
+		}
+
+		authenticators = append(authenticators, a)
+	}
+
+	if app.cfg.TLS.Enabled() && app.cfg.TLS.ClientCAFile != "" {
+		scopes, err := auth.ParseScopes(app.cfg.TLS.ClientCertScopes)
+		if err != nil {
+			return nil, fmt.Errorf("auth: client cert: %w", err)
+		}
+
+		authenticators = append(authenticators, auth.NewClientCertAuthenticator(scopes))
+	}
+
+	m := auth.NewMiddleware(app.l, authenticators...)
+
+	switch {
+	case app.cfg.Auth.Disabled && len(authenticators) > 0:
+		return nil, fmt.Errorf("auth: %w", errAuthConflict)
+	case app.cfg.Auth.Disabled:
+		m.Disable()
+	case len(authenticators) == 0:
+		return nil, fmt.Errorf("auth: %w", errNoCredentials)
+	}
+
+	m.Limit(auth.NewLimiter(auth.LimiterOptions{
+		Rate:          app.cfg.Auth.RateLimit,
+		Burst:         app.cfg.Auth.RateBurst,
+		MaxFailures:   app.cfg.Auth.MaxFailures,
+		FailureWindow: app.cfg.Auth.FailureWindow,
+		Lockout:       app.cfg.Auth.Lockout,
+	}, notifier, app.l))
+
+	return m, nil
+}
+
+// TelegramOptions renders notifications with the configured catalog. Devices may be nil.
+func TelegramOptions(cfg *config.Config, devices message.DeviceInfo) (telegram.Options, error) {
+	catalog, err := cfg.Notify.LoadCatalog()
+	if err != nil {
+		return telegram.Options{}, err
+	}
+
+	format, err := cfg.Telegram.Format()
+	if err != nil {
+		return telegram.Options{}, err
+	}
+
+	silent, err := cfg.Telegram.SilentSeverities()
+	if err != nil {
+		return telegram.Options{}, err
+	}
+
+	renderer, err := message.NewRenderer(catalog, format, devices)
+	if err != nil {
+		return telegram.Options{}, err
+	}
+
+	return telegram.Options{Renderer: renderer, Silent: silent}, nil
+}
+
+func (app *App) alarmOptions(devices *registry.Registry) alarm.Options {
+	return alarm.Options{
+		ArmingDelay:  app.cfg.Alarm.ArmingDelay,
+		PendingDelay: app.cfg.Alarm.PendingDelay,
+		DeviceModes:  devices.DeviceModes(),
+	}
+}
+
+// drain waits for queued notifications to be handed to the outbox.
+func (app *App) drain(d *notify.Dispatcher) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := d.Close(ctx); err != nil {
+		app.l.Error("failed to drain notifications", "err", err)
+	}
+}
+
+// closeOutbox makes one more attempt to deliver queued notifications before stopping.
+func (app *App) closeOutbox(o *notify.Outbox) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := o.Close(ctx); err != nil {
+		app.l.Error("failed to deliver notifications on shutdown", "err", err)
+	}
+}
+
+func (app *App) digestOptions() notify.DigestOptions {
+	// the time is checked by config validation
+	at, _ := app.cfg.Notify.DigestTime()
+
+	return notify.DigestOptions{At: at, LowBattery: app.cfg.Notify.DigestLowBattery}
+}
+
+func (app *App) messengerOptions() alarm.MessengerOptions {
+	// overrides are checked by config validation
+	overrides, _ := app.cfg.Alarm.Throttle.PolicyOverrides()
+
+	return alarm.MessengerOptions{
+		Throttle:          app.cfg.Alarm.Throttle.Policy(),
+		ThrottleOverrides: overrides,
+	}
+}
@@ -1,20 +1,51 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"time"
 
+	"github.com/SuddenGunter/hsd/alarm"
+	"github.com/SuddenGunter/hsd/notify/message"
+	"github.com/SuddenGunter/hsd/registry"
 	"github.com/caarlos0/env/v11"
 )
 
 // Config represents the configuration of the app.
 type Config struct {
+	// File is the optional YAML config file. Environment variables override values from the file.
+	File string `env:"CONFIG_FILE"`
+
 	Port int `env:"PORT,required"`
 
 	MQTT mqttConfig `envPrefix:"MQTT_"`
 
 	Z2MDevices []string `env:"Z2M_DEVICES"`
+	// DevicesPath is where devices changed via API are stored, empty to keep them in memory.
+	DevicesPath string `env:"DEVICES_PATH"`
+	// Devices can only be set in the config file. They replace Z2M_DEVICES and the ALARM_*_DEVICES lists.
+	Devices []alarm.DeviceConfig `env:"-"`
+
+	Alarm alarmConfig `envPrefix:"ALARM_"`
 
 	Telegram telegramConfig `envPrefix:"TELEGRAM_"`
+
+	Notify notifyConfig `envPrefix:"NOTIFY_"`
+
+	HomeAssistant homeAssistantConfig `envPrefix:"HASS_"`
+
+	Events eventsConfig `envPrefix:"EVENTS_"`
+
+	Audit auditConfig `envPrefix:"AUDIT_"`
+
+	Auth authConfig `envPrefix:"AUTH_"`
+
+	TLS tlsConfig `envPrefix:"TLS_"`
+
+	Health healthConfig `envPrefix:"HEALTH_"`
 }
 
 type mqttConfig struct {
@@ -24,19 +55,406 @@ type mqttConfig struct {
 	Password   string `env:"PASSWORD,required"`
 }
 
+type alarmConfig struct {
+	ArmingDelay  time.Duration `env:"ARMING_DELAY" envDefault:"0s"`
+	PendingDelay time.Duration `env:"PENDING_DELAY" envDefault:"0s"`
+	// HomeDevices and NightDevices list devices monitored in the respective modes, all devices are monitored if empty.
+	HomeDevices  []string `env:"HOME_DEVICES"`
+	NightDevices []string `env:"NIGHT_DEVICES"`
+	// Throttle limits how often each device alarms, see alarm.ThrottlePolicy.
+	Throttle throttleConfig `envPrefix:"THROTTLE_"`
+}
+
+type throttleConfig struct {
+	Window time.Duration `env:"WINDOW" envDefault:"1s"`
+	Max    int           `env:"MAX" envDefault:"1"`
+	Edge   string        `env:"EDGE" envDefault:"leading"`
+	Key    string        `env:"KEY" envDefault:"message"`
+	// Overrides are "target:window[:max[:edge[:key]]]" entries for a device name or an alarm kind.
+	Overrides []string `env:"OVERRIDES"`
+}
+
+// Policy returns the default throttle policy.
+func (c throttleConfig) Policy() alarm.ThrottlePolicy {
+	return alarm.ThrottlePolicy{Window: c.Window, Max: c.Max, Edge: alarm.Edge(c.Edge), Key: alarm.ThrottleKey(c.Key)}
+}
+
+// PolicyOverrides returns throttle policies by device name or alarm kind.
+func (c throttleConfig) PolicyOverrides() (map[string]alarm.ThrottlePolicy, error) {
+	overrides := make(map[string]alarm.ThrottlePolicy, len(c.Overrides))
+
+	for _, entry := range c.Overrides {
+		target, p, err := alarm.ParseThrottleOverride(entry, c.Policy())
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := overrides[target]; ok {
+			return nil, fmt.Errorf("duplicate target %q", target)
+		}
+
+		overrides[target] = p
+	}
+
+	return overrides, nil
+}
+
 type telegramConfig struct {
 	BotToken string `env:"BOT_TOKEN,required"`
 	ChatID   int64  `env:"CHAT_ID,required"`
+	// ParseMode is the markup of notifications: MarkdownV2, HTML or empty for plain text.
+	ParseMode string `env:"PARSE_MODE"`
+	// Silent lists severities delivered without a sound.
+	Silent []string `env:"SILENT"`
+}
+
+// Format returns ParseMode as a message format.
+func (c telegramConfig) Format() (message.Format, error) {
+	return message.ParseFormat(c.ParseMode)
+}
+
+// SilentSeverities returns Silent as severities.
+func (c telegramConfig) SilentSeverities() ([]message.Severity, error) {
+	severities := make([]message.Severity, 0, len(c.Silent))
+
+	for _, s := range c.Silent {
+		if !slices.Contains(message.Severities, message.Severity(s)) {
+			return nil, fmt.Errorf("unknown severity %q", s)
+		}
+
+		severities = append(severities, message.Severity(s))
+	}
+
+	return severities, nil
+}
+
+type notifyConfig struct {
+	// BatchWindow is how long notifications following a delivered one are coalesced into a summary, 0 disables batching.
+	BatchWindow time.Duration `env:"BATCH_WINDOW" envDefault:"2s"`
+	// DigestAt is the local time of day (HH:MM) the daily digest is sent, the digest is disabled if empty.
+	DigestAt         string `env:"DIGEST_AT"`
+	DigestLowBattery int    `env:"DIGEST_LOW_BATTERY" envDefault:"20"`
+	// OutboxPath is where undelivered notifications are kept, they are kept in memory only if empty.
+	// Defaults to outbox.json if unset.
+	OutboxPath string        `env:"OUTBOX_PATH"`
+	RetryMin   time.Duration `env:"RETRY_MIN" envDefault:"1s"`
+	RetryMax   time.Duration `env:"RETRY_MAX" envDefault:"5m"`
+	// Workers, QueueSize and EnqueueTimeout configure the pool delivering notifications, see notify.Dispatcher.
+	Workers        int           `env:"WORKERS" envDefault:"2"`
+	QueueSize      int           `env:"QUEUE_SIZE" envDefault:"100"`
+	EnqueueTimeout time.Duration `env:"ENQUEUE_TIMEOUT" envDefault:"1s"`
+	// LateAfter is the delay after which notifications are delivered with their original time.
+	LateAfter time.Duration `env:"LATE_AFTER" envDefault:"1m"`
+	// Catalog is a YAML file with message templates and translations, merged over the built-in English catalog.
+	Catalog string `env:"CATALOG"`
+}
+
+// LoadCatalog returns the message catalog, English if Catalog is empty.
+func (c notifyConfig) LoadCatalog() (*message.Catalog, error) {
+	if c.Catalog == "" {
+		return message.English(), nil
+	}
+
+	return message.LoadCatalog(c.Catalog)
+}
+
+// DigestTime returns DigestAt as an offset from midnight.
+func (c notifyConfig) DigestTime() (time.Duration, error) {
+	t, err := time.Parse("15:04", c.DigestAt)
+	if err != nil {
+		return 0, fmt.Errorf("expected HH:MM: %w", err)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+type homeAssistantConfig struct {
+	Enabled         bool   `env:"ENABLED"`
+	DiscoveryPrefix string `env:"DISCOVERY_PREFIX" envDefault:"homeassistant"`
+	TopicPrefix     string `env:"TOPIC_PREFIX" envDefault:"hsd"`
+}
+
+type eventsConfig struct {
+	// Path to the event log file, events are kept in memory only if empty. Defaults to events.jsonl if unset.
+	Path string `env:"PATH"`
+	// Retention and MaxEvents limit the stored events, 0 disables the limit.
+	Retention time.Duration `env:"RETENTION" envDefault:"720h"`
+	MaxEvents int           `env:"MAX_EVENTS" envDefault:"100000"`
+}
+
+type auditConfig struct {
+	// Path of the audit log, empty to only write audit entries to the application log. Defaults to audit.jsonl if unset.
+	Path string `env:"PATH"`
+}
+
+type authConfig struct {
+	// Tokens are "name:token:scope1+scope2" entries for bearer token auth.
+	Tokens []string `env:"TOKENS"`
+	// Users are "name:hash:scope1+scope2" entries for HTTP basic auth.
+	Users []string `env:"USERS"`
+	// Disabled lets all API requests in without credentials. Otherwise some credentials are required.
+	Disabled bool `env:"DISABLED"`
+
+	// RateLimit is the number of state changing requests per minute allowed per client, 0 disables rate limiting.
+	RateLimit float64 `env:"RATE_LIMIT" envDefault:"30"`
+	RateBurst int     `env:"RATE_BURST" envDefault:"10"`
+	// MaxFailures is the number of failed authentication attempts within FailureWindow that locks a client out,
+	// 0 disables lockout.
+	MaxFailures   int           `env:"MAX_FAILURES" envDefault:"5"`
+	FailureWindow time.Duration `env:"FAILURE_WINDOW" envDefault:"15m"`
+	Lockout       time.Duration `env:"LOCKOUT" envDefault:"15m"`
 }
 
-// LoadEnv loads the configuration from the environment.
+// tlsConfig configures HTTPS. HTTPS is enabled if both certificate and key files are set.
+type tlsConfig struct {
+	CertFile string `env:"CERT_FILE"`
+	KeyFile  string `env:"KEY_FILE"`
+	// ReloadInterval is how often certificate files are checked for changes.
+	ReloadInterval time.Duration `env:"RELOAD_INTERVAL" envDefault:"1m"`
+	// ClientCAFile enables client certificate authentication.
+	ClientCAFile     string `env:"CLIENT_CA_FILE"`
+	ClientCertScopes string `env:"CLIENT_CERT_SCOPES" envDefault:"arm+disarm"`
+	// RedirectPort starts a plain HTTP listener redirecting to HTTPS, disabled if 0.
+	RedirectPort int `env:"REDIRECT_PORT"`
+}
+
+// Enabled returns true if HTTPS is configured.
+func (c tlsConfig) Enabled() bool {
+	return c.CertFile != "" && c.KeyFile != ""
+}
+
+type healthConfig struct {
+	// MaxMessageAge is how long hsd may go without zigbee2mqtt messages before it is reported as not ready.
+	MaxMessageAge time.Duration `env:"MAX_MESSAGE_AGE" envDefault:"2h"`
+}
+
+// unsetDefaults are defaults applied only if the variable is not set. Unlike envDefault, which also replaces
+// empty values, they let an empty path disable the file.
+var unsetDefaults = map[string]string{
+	"DEVICES_PATH":       "devices.json",
+	"EVENTS_PATH":        "events.jsonl",
+	"AUDIT_PATH":         "audit.jsonl",
+	"NOTIFY_OUTBOX_PATH": "outbox.json",
+}
+
+// LoadEnv loads the configuration from the environment, and from the config file if CONFIG_FILE is set.
 func LoadEnv() (*Config, error) {
+	return Load(os.Getenv("CONFIG_FILE"))
+}
+
+// Load loads the configuration from the optional YAML config file at path and validates it.
+// Environment variables override values from the file. Secrets can be read from files, see secrets.
+func Load(path string) (*Config, error) {
+	vars := make(map[string]string)
+
+	var devices []alarm.DeviceConfig
+
+	if path != "" {
+		fc, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := resolveSecrets(fc.values); err != nil {
+			return nil, err
+		}
+
+		maps.Copy(vars, fc.values)
+		devices = fc.devices
+	}
+
+	// secrets are resolved per source, so that e.g. MQTT_PASSWORD_FILE overrides mqtt.password from the file
+	environ := env.ToMap(os.Environ())
+	if err := resolveSecrets(environ); err != nil {
+		return nil, err
+	}
+
+	maps.Copy(vars, environ)
+	vars["CONFIG_FILE"] = path
+
+	for key, value := range unsetDefaults {
+		if _, ok := vars[key]; !ok {
+			vars[key] = value
+		}
+	}
+
 	cfg := Config{}
 
-	err := env.Parse(&cfg)
+	err := env.ParseWithOptions(&cfg, env.Options{Environment: vars})
 	if err != nil {
-		return nil, fmt.Errorf("env: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+
+	cfg.Devices = devices
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
 
 	return &cfg, nil
 }
+
+// Validate checks the config for values that parse but can't work, e.g. unknown or duplicate devices.
+func (c *Config) Validate() error {
+	var errs []error
+
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Port > 0 && c.Port < 65536, "PORT: %d is not a valid port", c.Port)
+	check(c.MQTT.BrokerPort > 0 && c.MQTT.BrokerPort < 65536, "MQTT_BROKER_PORT: %d is not a valid port", c.MQTT.BrokerPort)
+	check(c.Alarm.ArmingDelay >= 0, "ALARM_ARMING_DELAY: must not be negative")
+	check(c.Alarm.PendingDelay >= 0, "ALARM_PENDING_DELAY: must not be negative")
+	check(c.Notify.BatchWindow >= 0, "NOTIFY_BATCH_WINDOW: must not be negative")
+	check(c.Notify.RetryMin > 0 && c.Notify.RetryMax >= c.Notify.RetryMin, "NOTIFY_RETRY_MIN and NOTIFY_RETRY_MAX: must be positive, min not above max")
+	check(c.Notify.Workers > 0, "NOTIFY_WORKERS: must be positive")
+	check(c.Notify.QueueSize > 0, "NOTIFY_QUEUE_SIZE: must be positive")
+	check(c.Notify.EnqueueTimeout >= 0, "NOTIFY_ENQUEUE_TIMEOUT: must not be negative")
+	check(c.Notify.DigestLowBattery >= 0 && c.Notify.DigestLowBattery <= 100, "NOTIFY_DIGEST_LOW_BATTERY: must be a percentage")
+	check(c.Events.Retention >= 0, "EVENTS_RETENTION: must not be negative")
+	check(c.Events.MaxEvents >= 0, "EVENTS_MAX_EVENTS: must not be negative")
+	check(c.Auth.RateLimit >= 0, "AUTH_RATE_LIMIT: must not be negative")
+	check(c.Auth.RateLimit == 0 || c.Auth.RateBurst > 0, "AUTH_RATE_BURST: must be positive when rate limiting is enabled")
+	check(c.Auth.MaxFailures >= 0, "AUTH_MAX_FAILURES: must not be negative")
+	check(c.Auth.MaxFailures == 0 || c.Auth.FailureWindow > 0 && c.Auth.Lockout > 0,
+		"AUTH_FAILURE_WINDOW and AUTH_LOCKOUT: must be positive when lockout is enabled")
+	check(c.Health.MaxMessageAge > 0, "HEALTH_MAX_MESSAGE_AGE: must be positive")
+	check((c.TLS.CertFile == "") == (c.TLS.KeyFile == ""), "TLS_CERT_FILE and TLS_KEY_FILE: must be set together")
+	check(c.TLS.ClientCAFile == "" || c.TLS.Enabled(), "TLS_CLIENT_CA_FILE: requires TLS_CERT_FILE and TLS_KEY_FILE")
+	check(!c.TLS.Enabled() || c.TLS.ReloadInterval > 0, "TLS_RELOAD_INTERVAL: must be positive")
+
+	if c.Notify.DigestAt != "" {
+		if _, err := c.Notify.DigestTime(); err != nil {
+			errs = append(errs, fmt.Errorf("NOTIFY_DIGEST_AT: %w", err))
+		}
+	}
+
+	errs = append(errs, c.validateMessages()...)
+
+	if err := c.Alarm.Throttle.Policy().Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("ALARM_THROTTLE_*: %w", err))
+	}
+
+	if _, err := c.Alarm.Throttle.PolicyOverrides(); err != nil {
+		errs = append(errs, fmt.Errorf("ALARM_THROTTLE_OVERRIDES: %w", err))
+	}
+
+	errs = append(errs, c.validateDevices()...)
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%w:\n%w", ErrInvalidConfig, err)
+	}
+
+	return nil
+}
+
+func (c *Config) validateMessages() []error {
+	var errs []error
+
+	if _, err := c.Telegram.SilentSeverities(); err != nil {
+		errs = append(errs, fmt.Errorf("TELEGRAM_SILENT: %w", err))
+	}
+
+	f, err := c.Telegram.Format()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("TELEGRAM_PARSE_MODE: %w", err))
+	}
+
+	catalog, err := c.Notify.LoadCatalog()
+	if err != nil {
+		return append(errs, fmt.Errorf("NOTIFY_CATALOG: %w", err))
+	}
+
+	if _, err := message.NewRenderer(catalog, f, nil); err != nil {
+		errs = append(errs, fmt.Errorf("NOTIFY_CATALOG: %w", err))
+	}
+
+	return errs
+}
+
+func (c *Config) validateDevices() []error {
+	var errs []error
+
+	if len(c.Devices) > 0 {
+		if len(c.Z2MDevices) > 0 || len(c.Alarm.HomeDevices) > 0 || len(c.Alarm.NightDevices) > 0 {
+			errs = append(errs, errors.New("devices: can't be combined with Z2M_DEVICES, ALARM_HOME_DEVICES or ALARM_NIGHT_DEVICES"))
+		}
+
+		seen := make(map[string]bool, len(c.Devices))
+
+		for _, d := range c.Devices {
+			if err := registry.Validate(d); err != nil {
+				errs = append(errs, fmt.Errorf("devices: %q: %w", d.Name, err))
+			}
+
+			if seen[d.Name] {
+				errs = append(errs, fmt.Errorf("devices: duplicate device %q", d.Name))
+			}
+
+			seen[d.Name] = true
+		}
+
+		return errs
+	}
+
+	seen := make(map[string]bool, len(c.Z2MDevices))
+
+	for _, name := range c.Z2MDevices {
+		if err := registry.Validate(alarm.DeviceConfig{Name: name}); err != nil {
+			errs = append(errs, fmt.Errorf("Z2M_DEVICES: %q: %w", name, err))
+		}
+
+		if seen[name] {
+			errs = append(errs, fmt.Errorf("Z2M_DEVICES: duplicate device %q", name))
+		}
+
+		seen[name] = true
+	}
+
+	for key, names := range map[string][]string{"ALARM_HOME_DEVICES": c.Alarm.HomeDevices, "ALARM_NIGHT_DEVICES": c.Alarm.NightDevices} {
+		for _, name := range names {
+			if !seen[name] {
+				errs = append(errs, fmt.Errorf("%s: unknown device %q, it must be listed in Z2M_DEVICES", key, name))
+			}
+		}
+	}
+
+	return errs
+}
+
+// DeviceConfigs returns configured devices, either from the config file or built from
+// Z2M_DEVICES and the ALARM_*_DEVICES lists.
+func (c *Config) DeviceConfigs() []alarm.DeviceConfig {
+	if len(c.Devices) > 0 {
+		return slices.Clone(c.Devices)
+	}
+
+	devices := make([]alarm.DeviceConfig, 0, len(c.Z2MDevices))
+
+	for _, name := range c.Z2MDevices {
+		d := alarm.DeviceConfig{Name: name}
+
+		home := len(c.Alarm.HomeDevices) == 0 || slices.Contains(c.Alarm.HomeDevices, name)
+		night := len(c.Alarm.NightDevices) == 0 || slices.Contains(c.Alarm.NightDevices, name)
+
+		if !home || !night {
+			// away mode always monitors all devices
+			d.Modes = []alarm.Mode{alarm.ModeAway}
+
+			if home {
+				d.Modes = append(d.Modes, alarm.ModeHome)
+			}
+
+			if night {
+				d.Modes = append(d.Modes, alarm.ModeNight)
+			}
+		}
+
+		devices = append(devices, d)
+	}
+
+	return devices
+}
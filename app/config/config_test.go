@@ -1,9 +1,13 @@
 package config_test
 
 import (
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/SuddenGunter/hsd/alarm"
 	"github.com/SuddenGunter/hsd/app/config"
+	"github.com/SuddenGunter/hsd/notify/message"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -191,3 +195,97 @@ func TestLoadEnv_InvalidChatIDValue(t *testing.T) {
 	require.Error(t, err)
 	assert.Nil(t, cfg)
 }
+
+func TestLoadEnv_HomeAssistant(t *testing.T) {
+	t.Setenv("PORT", "8080")
+	t.Setenv("MQTT_BROKER_HOST", "localhost")
+	t.Setenv("MQTT_USERNAME", "testuser")
+	t.Setenv("MQTT_PASSWORD", "testpass")
+	t.Setenv("TELEGRAM_BOT_TOKEN", "123456:ABC-DEF1234")
+	t.Setenv("TELEGRAM_CHAT_ID", "12345")
+	t.Setenv("HASS_ENABLED", "true")
+
+	cfg, err := config.LoadEnv()
+
+	require.NoError(t, err)
+	assert.True(t, cfg.HomeAssistant.Enabled)
+	assert.Equal(t, "homeassistant", cfg.HomeAssistant.DiscoveryPrefix) // default value
+	assert.Equal(t, "hsd", cfg.HomeAssistant.TopicPrefix)               // default value
+}
+
+func TestLoadEnv_Throttle(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("MQTT_PASSWORD", "testpass")
+	t.Setenv("TELEGRAM_BOT_TOKEN", "123456:ABC-DEF1234")
+	t.Setenv("ALARM_THROTTLE_EDGE", "trailing")
+	t.Setenv("ALARM_THROTTLE_OVERRIDES", "unavailable:10m,door1:5s:3:leading")
+
+	cfg, err := config.LoadEnv()
+	require.NoError(t, err)
+
+	assert.Equal(t, alarm.ThrottlePolicy{Window: time.Second, Max: 1, Edge: alarm.EdgeTrailing, Key: alarm.ThrottleByMessage}, cfg.Alarm.Throttle.Policy())
+
+	overrides, err := cfg.Alarm.Throttle.PolicyOverrides()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]alarm.ThrottlePolicy{
+		"unavailable": {Window: 10 * time.Minute, Max: 1, Edge: alarm.EdgeTrailing, Key: alarm.ThrottleByMessage},
+		"door1":       {Window: 5 * time.Second, Max: 3, Edge: alarm.EdgeLeading, Key: alarm.ThrottleByMessage},
+	}, overrides)
+
+	t.Setenv("ALARM_THROTTLE_OVERRIDES", "door1:5s,door1:1m")
+
+	_, err = config.LoadEnv()
+	require.ErrorIs(t, err, config.ErrInvalidConfig)
+	assert.ErrorContains(t, err, `duplicate target "door1"`)
+}
+
+func TestLoadEnv_Messages(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("MQTT_PASSWORD", "testpass")
+	t.Setenv("TELEGRAM_BOT_TOKEN", "123456:ABC-DEF1234")
+	t.Setenv("TELEGRAM_PARSE_MODE", "HTML")
+	t.Setenv("TELEGRAM_SILENT", "info,warning")
+
+	cfg, err := config.LoadEnv()
+	require.NoError(t, err)
+
+	silent, err := cfg.Telegram.SilentSeverities()
+	require.NoError(t, err)
+	assert.Equal(t, []message.Severity{message.Info, message.Warning}, silent)
+
+	t.Setenv("TELEGRAM_PARSE_MODE", "Markdown")
+	t.Setenv("TELEGRAM_SILENT", "quiet")
+	t.Setenv("NOTIFY_CATALOG", filepath.Join(t.TempDir(), "missing.yaml"))
+
+	_, err = config.LoadEnv()
+	require.ErrorIs(t, err, config.ErrInvalidConfig)
+	assert.ErrorContains(t, err, "TELEGRAM_PARSE_MODE")
+	assert.ErrorContains(t, err, `TELEGRAM_SILENT: unknown severity "quiet"`)
+	assert.ErrorContains(t, err, "NOTIFY_CATALOG")
+}
+
+func TestLoadEnv_EmptyPaths(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("MQTT_PASSWORD", "testpass")
+	t.Setenv("TELEGRAM_BOT_TOKEN", "123456:ABC-DEF1234")
+
+	cfg, err := config.LoadEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "events.jsonl", cfg.Events.Path)
+	assert.Equal(t, "audit.jsonl", cfg.Audit.Path)
+	assert.Equal(t, "outbox.json", cfg.Notify.OutboxPath)
+	assert.Equal(t, "devices.json", cfg.DevicesPath)
+
+	// empty values disable the files instead of falling back to defaults
+	t.Setenv("EVENTS_PATH", "")
+	t.Setenv("AUDIT_PATH", "")
+	t.Setenv("NOTIFY_OUTBOX_PATH", "")
+	t.Setenv("DEVICES_PATH", "")
+
+	cfg, err = config.LoadEnv()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Events.Path)
+	assert.Empty(t, cfg.Audit.Path)
+	assert.Empty(t, cfg.Notify.OutboxPath)
+	assert.Empty(t, cfg.DevicesPath)
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Environ returns the effective config as sorted KEY=value environment variables, with secrets redacted.
+// Devices from the config file have no environment variable and are not included.
+func (c Config) Environ() []string {
+	var vars []string
+
+	for name, value := range variables(c.Redacted()) {
+		vars = append(vars, name+"="+value)
+	}
+
+	slices.Sort(vars)
+
+	return vars
+}
+
+// Changed returns the sorted names of environment variables whose effective values differ in next.
+// Secrets are compared as well, but only their names are returned.
+func (c Config) Changed(next Config) []string {
+	a, b := variables(c), variables(next)
+
+	var changed []string
+
+	for name, value := range a {
+		if b[name] != value {
+			changed = append(changed, name)
+		}
+	}
+
+	slices.Sort(changed)
+
+	return changed
+}
+
+func variables(c Config) map[string]string {
+	vars := make(map[string]string)
+	environ(reflect.ValueOf(c), "", vars)
+
+	return vars
+}
+
+func environ(v reflect.Value, prefix string, vars map[string]string) {
+	t := v.Type()
+
+	for i := range t.NumField() {
+		f := t.Field(i)
+
+		if p, ok := f.Tag.Lookup("envPrefix"); ok {
+			environ(v.Field(i), prefix+p, vars)
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("env"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		vars[prefix+name] = format(v.Field(i).Interface())
+	}
+}
+
+func format(v any) string {
+	switch v := v.(type) {
+	case time.Duration:
+		return v.String()
+	case []string:
+		return strings.Join(v, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
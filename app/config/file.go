@@ -0,0 +1,147 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	"github.com/caarlos0/env/v11"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInvalidConfig is returned when the config file or environment variables can't be parsed, or the config fails validation.
+var ErrInvalidConfig = errors.New("invalid config")
+
+// devicesKey is the config file key of structured device configs, which have no environment variable.
+const devicesKey = "devices"
+
+// fileConfig is the config file representation. Keys mirror environment variables:
+// a variable with a prefix, e.g. MQTT_BROKER_HOST, is set as broker_host in the mqtt section.
+type fileConfig struct {
+	values  map[string]string
+	devices []alarm.DeviceConfig
+}
+
+// readFile reads the YAML config file and maps its keys to environment variable names.
+func readFile(path string) (fileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fileConfig{}, fmt.Errorf("config file: %w", err)
+	}
+
+	var root map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return fileConfig{}, fmt.Errorf("%w: %s: %w", ErrInvalidConfig, path, err)
+	}
+
+	known, sections, err := knownKeys()
+	if err != nil {
+		return fileConfig{}, err
+	}
+
+	fc := fileConfig{values: make(map[string]string)}
+
+	var errs []error
+
+	for key, node := range root {
+		switch {
+		case key == devicesKey:
+			dec := yaml.NewDecoder(bytes.NewReader(mustMarshal(&node)))
+			dec.KnownFields(true)
+
+			if err := dec.Decode(&fc.devices); err != nil {
+				errs = append(errs, fmt.Errorf("devices: %w", err))
+			}
+		case slices.Contains(sections, key):
+			var section map[string]yaml.Node
+			if err := node.Decode(&section); err != nil {
+				errs = append(errs, fmt.Errorf("%s: expected a mapping", key))
+				continue
+			}
+
+			for k, v := range section {
+				errs = append(errs, fc.set(known, key+"."+k, &v))
+			}
+		default:
+			errs = append(errs, fc.set(known, key, &node))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fileConfig{}, fmt.Errorf("%w: %s:\n%w", ErrInvalidConfig, path, err)
+	}
+
+	return fc, nil
+}
+
+// set stores the value of a dotted key under its environment variable name.
+func (fc fileConfig) set(known map[string]bool, key string, node *yaml.Node) error {
+	name := strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+	if !known[name] {
+		return fmt.Errorf("%s: unknown key", key)
+	}
+
+	switch node.Kind {
+	case yaml.ScalarNode:
+		fc.values[name] = node.Value
+	case yaml.SequenceNode:
+		items := make([]string, 0, len(node.Content))
+
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return fmt.Errorf("%s: expected a list of values", key)
+			}
+
+			items = append(items, item.Value)
+		}
+
+		fc.values[name] = strings.Join(items, ",")
+	default:
+		return fmt.Errorf("%s: expected a value or a list of values", key)
+	}
+
+	return nil
+}
+
+// knownKeys returns environment variables of Config and the config file sections, which are lowercase env prefixes.
+func knownKeys() (map[string]bool, []string, error) {
+	params, err := env.GetFieldParams(&Config{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("config: %w", err)
+	}
+
+	known := make(map[string]bool, len(params))
+	for _, p := range params {
+		known[p.Key] = true
+	}
+
+	for name := range secrets {
+		known[name+fileSuffix] = true
+	}
+
+	var sections []string
+
+	t := reflect.TypeFor[Config]()
+	for i := range t.NumField() {
+		if prefix, ok := t.Field(i).Tag.Lookup("envPrefix"); ok {
+			sections = append(sections, strings.ToLower(strings.TrimSuffix(prefix, "_")))
+		}
+	}
+
+	return known, sections, nil
+}
+
+func mustMarshal(node *yaml.Node) []byte {
+	// re-encoding a node that was just decoded can't fail
+	data, err := yaml.Marshal(node)
+	if err != nil {
+		panic(err)
+	}
+
+	return data
+}
@@ -0,0 +1,95 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	"github.com/SuddenGunter/hsd/app/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const baseConfig = `
+port: 8080
+mqtt:
+  broker_host: localhost
+  username: hsd
+  password: secret
+telegram:
+  bot_token: "123456:ABC-DEF1234"
+  chat_id: 12345
+`
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "hsd.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+//nolint:paralleltest // Load reads the environment
+func TestLoad_FileWithEnvOverrides(t *testing.T) {
+	t.Setenv("MQTT_BROKER_HOST", "mqtt.example.com")
+
+	path := writeConfig(t, baseConfig+`
+alarm:
+  arming_delay: 30s
+hass:
+  enabled: true
+auth:
+  tokens:
+    - "alice:t1:read+arm"
+    - "bob:t2:read"
+devices:
+  - name: door1
+  - name: window1
+    modes: [away, night]
+`)
+
+	cfg, err := config.Load(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, path, cfg.File)
+	assert.Equal(t, 8080, cfg.Port)
+	assert.Equal(t, "mqtt.example.com", cfg.MQTT.BrokerHost)
+	assert.Equal(t, 1883, cfg.MQTT.BrokerPort)
+	assert.Equal(t, 30*time.Second, cfg.Alarm.ArmingDelay)
+	assert.True(t, cfg.HomeAssistant.Enabled)
+	assert.Equal(t, []string{"alice:t1:read+arm", "bob:t2:read"}, cfg.Auth.Tokens)
+	assert.Equal(t, []alarm.DeviceConfig{
+		{Name: "door1"},
+		{Name: "window1", Modes: []alarm.Mode{alarm.ModeAway, alarm.ModeNight}},
+	}, cfg.DeviceConfigs())
+}
+
+//nolint:paralleltest // Load reads the environment
+func TestLoad_InvalidFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{name: "unknown key", config: "hass:\n  enabld: true\n", err: "hass.enabld: unknown key"},
+		{name: "unknown section", config: "zones: {}\n", err: "zones: unknown key"},
+		{name: "unknown device field", config: "devices:\n  - name: door1\n    zone: hall\n", err: "field zone not found"},
+		{name: "bad duration", config: "alarm:\n  pending_delay: 30 seconds\n", err: "PendingDelay"},
+		{name: "duplicate device", config: "devices:\n  - name: door1\n  - name: door1\n", err: `duplicate device "door1"`},
+		{name: "unknown mode device", config: "z2m_devices: [door1]\nalarm:\n  night_devices: [door2]\n", err: `unknown device "door2"`},
+		{name: "invalid device name", config: "z2m_devices: [a/b]\n", err: `"a/b"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := config.Load(writeConfig(t, baseConfig+tt.config))
+
+			require.Error(t, err)
+			assert.Nil(t, cfg)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const (
+	fileSuffix = "_FILE"
+	redacted   = "[redacted]"
+)
+
+// secrets are environment variables that can also be read from a file named by the variable with the _FILE suffix,
+// e.g. MQTT_PASSWORD_FILE=/run/secrets/mqtt_password. Lists are read one entry per line.
+var secrets = map[string]bool{
+	"MQTT_PASSWORD":      false,
+	"TELEGRAM_BOT_TOKEN": false,
+	"AUTH_TOKENS":        true,
+	"AUTH_USERS":         true,
+}
+
+// resolveSecrets replaces _FILE variables in vars with the contents of the files they point to.
+func resolveSecrets(vars map[string]string) error {
+	for name, list := range secrets {
+		path, ok := vars[name+fileSuffix]
+		if !ok {
+			continue
+		}
+
+		delete(vars, name+fileSuffix)
+
+		if _, ok := vars[name]; ok {
+			return fmt.Errorf("%w: %s and %s%s can't be set together", ErrInvalidConfig, name, name, fileSuffix)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("%w: %s%s: %w", ErrInvalidConfig, name, fileSuffix, err)
+		}
+
+		value := strings.TrimSpace(string(data))
+		if list {
+			value = strings.Join(strings.Fields(value), ",")
+		}
+
+		vars[name] = value
+	}
+
+	return nil
+}
+
+// LogValue implements slog.LogValuer, so secrets never end up in logs.
+func (c Config) LogValue() slog.Value {
+	// plain has no LogValue method, otherwise slog would call it again
+	type plain Config
+
+	return slog.AnyValue(plain(c.Redacted()))
+}
+
+// Redacted returns a copy of the config with secrets replaced. Credential entries keep their names and scopes.
+func (c Config) Redacted() Config {
+	c.MQTT.Password = redact(c.MQTT.Password)
+	c.Telegram.BotToken = redact(c.Telegram.BotToken)
+	c.Auth.Tokens = redactCredentials(c.Auth.Tokens)
+	c.Auth.Users = redactCredentials(c.Auth.Users)
+
+	return c
+}
+
+func redact(s string) string {
+	if s == "" {
+		return ""
+	}
+
+	return redacted
+}
+
+// redactCredentials hides the secret of "name:secret:scopes" entries.
+func redactCredentials(entries []string) []string {
+	out := make([]string, 0, len(entries))
+
+	for _, e := range entries {
+		name, rest, _ := strings.Cut(e, ":")
+
+		scopes := ""
+		if i := strings.LastIndex(rest, ":"); i >= 0 {
+			scopes = rest[i+1:]
+		}
+
+		out = append(out, name+":"+redacted+":"+scopes)
+	}
+
+	return out
+}
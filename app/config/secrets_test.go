@@ -0,0 +1,125 @@
+package config_test
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SuddenGunter/hsd/app/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSecret(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+func setRequiredEnv(t *testing.T) {
+	t.Helper()
+
+	t.Setenv("PORT", "8080")
+	t.Setenv("MQTT_BROKER_HOST", "localhost")
+	t.Setenv("MQTT_USERNAME", "testuser")
+	t.Setenv("TELEGRAM_CHAT_ID", "12345")
+}
+
+//nolint:paralleltest // Load reads the environment
+func TestLoad_SecretFiles(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("MQTT_PASSWORD_FILE", writeSecret(t, "mqtt_password", "testpass\n"))
+	t.Setenv("TELEGRAM_BOT_TOKEN_FILE", writeSecret(t, "bot_token", "123456:ABC-DEF1234\n"))
+	t.Setenv("AUTH_TOKENS_FILE", writeSecret(t, "tokens", "alice:t1:read+arm\nbob:t2:read\n"))
+
+	cfg, err := config.LoadEnv()
+
+	require.NoError(t, err)
+	assert.Equal(t, "testpass", cfg.MQTT.Password)
+	assert.Equal(t, "123456:ABC-DEF1234", cfg.Telegram.BotToken)
+	assert.Equal(t, []string{"alice:t1:read+arm", "bob:t2:read"}, cfg.Auth.Tokens)
+}
+
+//nolint:paralleltest // Load reads the environment
+func TestLoad_SecretFileInConfigFile(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("TELEGRAM_BOT_TOKEN", "123456:ABC-DEF1234")
+
+	path := writeConfig(t, "mqtt:\n  password_file: "+writeSecret(t, "mqtt_password", "testpass")+"\n")
+
+	cfg, err := config.Load(path)
+
+	require.NoError(t, err)
+	assert.Equal(t, "testpass", cfg.MQTT.Password)
+}
+
+//nolint:paralleltest // Load reads the environment
+func TestLoad_SecretFileErrors(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("TELEGRAM_BOT_TOKEN", "123456:ABC-DEF1234")
+
+	t.Run("both set", func(t *testing.T) {
+		t.Setenv("MQTT_PASSWORD", "testpass")
+		t.Setenv("MQTT_PASSWORD_FILE", writeSecret(t, "mqtt_password", "testpass"))
+
+		_, err := config.LoadEnv()
+
+		require.ErrorIs(t, err, config.ErrInvalidConfig)
+		assert.Contains(t, err.Error(), "MQTT_PASSWORD and MQTT_PASSWORD_FILE can't be set together")
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		t.Setenv("MQTT_PASSWORD_FILE", filepath.Join(t.TempDir(), "missing"))
+
+		_, err := config.LoadEnv()
+
+		require.ErrorIs(t, err, config.ErrInvalidConfig)
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+//nolint:paralleltest // Load reads the environment
+func TestConfig_LogValueRedactsSecrets(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("MQTT_PASSWORD", "mqtt-secret")
+	t.Setenv("TELEGRAM_BOT_TOKEN", "123456:bot-secret")
+	t.Setenv("AUTH_TOKENS", "alice:token-secret:read+arm")
+	t.Setenv("AUTH_USERS", "bob:$2a$10$hash-secret:read")
+
+	cfg, err := config.LoadEnv()
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	slog.New(slog.NewTextHandler(buf, nil)).Info("config loaded", "cfg", cfg)
+
+	out := buf.String()
+	assert.NotContains(t, out, "secret")
+	assert.Contains(t, out, "alice:[redacted]:read+arm")
+	assert.Contains(t, out, "bob:[redacted]:read")
+	assert.Contains(t, out, "localhost")
+}
+
+//nolint:paralleltest // Load reads the environment
+func TestConfig_Environ(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("MQTT_PASSWORD", "mqtt-secret")
+	t.Setenv("TELEGRAM_BOT_TOKEN", "123456:bot-secret")
+	t.Setenv("Z2M_DEVICES", "door1,door2")
+
+	cfg, err := config.LoadEnv()
+	require.NoError(t, err)
+
+	vars := cfg.Environ()
+
+	assert.Contains(t, vars, "MQTT_PASSWORD=[redacted]")
+	assert.Contains(t, vars, "TELEGRAM_BOT_TOKEN=[redacted]")
+	assert.Contains(t, vars, "Z2M_DEVICES=door1,door2")
+	assert.Contains(t, vars, "ALARM_ARMING_DELAY=0s")
+	assert.Contains(t, vars, "EVENTS_RETENTION=720h0m0s")
+	assert.IsIncreasing(t, vars)
+}
@@ -0,0 +1,9 @@
+package app
+
+// ReloadConfig exposes reloadConfig to tests.
+func (app *App) ReloadConfig(devices deviceReconciler, notifier notifierReconfigurer) func() {
+	return app.reloadConfig(devices, notifier)
+}
+
+// RestartRequired exposes restartRequired to tests.
+var RestartRequired = restartRequired
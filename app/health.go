@@ -0,0 +1,76 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	healthhandler "github.com/SuddenGunter/hsd/api/health"
+	"github.com/SuddenGunter/hsd/telegram"
+	"github.com/SuddenGunter/hsd/z2m"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+var (
+	errMQTTDisconnected = errors.New("mqtt broker disconnected")
+	errNoMessages       = errors.New("no recent zigbee2mqtt messages")
+	errDeliveryFailed   = errors.New("last delivery failed")
+)
+
+// readinessChecks returns checks of the dependencies hsd needs to detect and report alarms.
+func (app *App) readinessChecks(
+	mc mqtt.Client,
+	z2ml *z2m.Zigbee2MQTTListener,
+	notifier *telegram.Notifier,
+	devMsg *alarm.DeviceMessenger,
+) map[string]healthhandler.Check {
+	started := time.Now()
+
+	return map[string]healthhandler.Check{
+		"mqtt": func(context.Context) (string, error) {
+			if !mc.IsConnectionOpen() {
+				return "", errMQTTDisconnected
+			}
+
+			return "connected", nil
+		},
+		"zigbee2mqtt": func(context.Context) (string, error) {
+			last := z2ml.LastMessage()
+			if last.IsZero() {
+				last = started
+			}
+
+			age := time.Since(last).Round(time.Second)
+			detail := fmt.Sprintf("last message %s ago", age)
+
+			if age > app.cfg.Health.MaxMessageAge {
+				return detail, errNoMessages
+			}
+
+			return detail, nil
+		},
+		"telegram": func(context.Context) (string, error) {
+			last, err := notifier.LastDelivery()
+			if last.IsZero() {
+				return "no messages sent yet", nil
+			}
+
+			detail := fmt.Sprintf("last delivery attempt at %s", last.Format(time.RFC3339))
+			// the endpoint is public, delivery errors are only logged
+			if err != nil {
+				return detail, errDeliveryFailed
+			}
+
+			return detail, nil
+		},
+		"devices": func(ctx context.Context) (string, error) {
+			if err := devMsg.Check(ctx); err != nil {
+				return "", err
+			}
+
+			return "all device goroutines responding", nil
+		},
+	}
+}
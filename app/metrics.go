@@ -0,0 +1,95 @@
+package app
+
+import (
+	"context"
+	"sync"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	"github.com/SuddenGunter/hsd/metrics"
+	"github.com/SuddenGunter/hsd/notify"
+)
+
+// newMetrics returns a registry of gauges computed from the current alarm and device state on every scrape.
+// It includes the metrics of the Default registry.
+func (app *App) newMetrics(alarmer *alarm.Alarmer, devMsg *alarm.DeviceMessenger, dispatcher *notify.Dispatcher, outbox *notify.Outbox) *metrics.Registry {
+	r := metrics.NewRegistry(metrics.Default)
+
+	r.NewGaugeFunc("hsd_notify_queue_depth", "Notifications waiting for a dispatch worker.", func(context.Context) []metrics.Sample {
+		return []metrics.Sample{{Value: float64(dispatcher.Len())}}
+	})
+
+	r.NewGaugeFunc("hsd_outbox_pending", "Notifications waiting for delivery.", func(context.Context) []metrics.Sample {
+		return []metrics.Sample{{Value: float64(outbox.Pending())}}
+	})
+
+	r.NewGaugeFunc("hsd_alarm_armed", "1 if the alarm is enabled (not disarmed).", func(context.Context) []metrics.Sample {
+		return []metrics.Sample{{Value: boolValue(alarmer.Enabled())}}
+	})
+
+	r.NewGaugeFunc("hsd_alarm_state", "1 for the current alarm state.", func(context.Context) []metrics.Sample {
+		current := alarmer.State()
+		samples := make([]metrics.Sample, 0, len(alarm.States))
+
+		for _, s := range alarm.States {
+			samples = append(samples, metrics.Sample{Values: []string{string(s)}, Value: boolValue(s == current)})
+		}
+
+		return samples
+	}, "state")
+
+	// device gauges share a snapshot taken once per scrape
+	var (
+		mux      sync.Mutex
+		statuses []alarm.DeviceStatus
+	)
+
+	r.BeforeWrite(func(ctx context.Context) {
+		s, err := devMsg.Statuses(ctx)
+		if err != nil {
+			app.l.Warn("failed to get device statuses for metrics", "err", err)
+			s = nil
+		}
+
+		mux.Lock()
+		defer mux.Unlock()
+
+		statuses = s
+	})
+
+	deviceGauge := func(name, help string, value func(alarm.DeviceStatus) float64) {
+		r.NewGaugeFunc(name, help, func(context.Context) []metrics.Sample {
+			mux.Lock()
+			defer mux.Unlock()
+
+			samples := make([]metrics.Sample, 0, len(statuses))
+			for _, s := range statuses {
+				samples = append(samples, metrics.Sample{Values: []string{s.Name}, Value: value(s)})
+			}
+
+			return samples
+		}, "device")
+	}
+
+	deviceGauge("hsd_device_available", "1 if the device is available.", func(s alarm.DeviceStatus) float64 {
+		return boolValue(s.Available)
+	})
+	deviceGauge("hsd_device_opened", "1 if the door is opened.", func(s alarm.DeviceStatus) float64 {
+		return boolValue(s.Opened)
+	})
+	deviceGauge("hsd_device_battery_percent", "Device battery level.", func(s alarm.DeviceStatus) float64 {
+		return float64(s.Battery)
+	})
+	deviceGauge("hsd_device_link_quality", "Device zigbee link quality.", func(s alarm.DeviceStatus) float64 {
+		return float64(s.LinkQuality)
+	})
+
+	return r
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
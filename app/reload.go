@@ -0,0 +1,65 @@
+package app
+
+import (
+	"github.com/SuddenGunter/hsd/alarm"
+	"github.com/SuddenGunter/hsd/app/config"
+)
+
+type deviceReconciler interface {
+	Reconcile(config []alarm.DeviceConfig) error
+}
+
+type notifierReconfigurer interface {
+	Reconfigure(tgBotToken string, chatID int64) error
+}
+
+// reloadConfig returns a function that loads the config again and applies device and notifier changes.
+// MQTT and HTTP connections are kept, other changes only take effect after a restart.
+func (app *App) reloadConfig(devices deviceReconciler, notifier notifierReconfigurer) func() {
+	current := app.cfg
+
+	return func() {
+		next, err := config.Load(current.File)
+		if err != nil {
+			app.l.Error("failed to reload config, keeping the current config", "err", err)
+			return
+		}
+
+		if err := devices.Reconcile(next.DeviceConfigs()); err != nil {
+			app.l.Error("failed to apply device changes", "err", err)
+		}
+
+		if next.Telegram.BotToken != current.Telegram.BotToken || next.Telegram.ChatID != current.Telegram.ChatID {
+			if err := notifier.Reconfigure(next.Telegram.BotToken, next.Telegram.ChatID); err != nil {
+				app.l.Error("failed to apply telegram changes", "err", err)
+
+				// retry on the next reload
+				next.Telegram.BotToken, next.Telegram.ChatID = current.Telegram.BotToken, current.Telegram.ChatID
+			}
+		}
+
+		if changed := restartRequired(current, next); len(changed) > 0 {
+			app.l.Warn("config changes require a restart", "variables", changed)
+		}
+
+		current = next
+
+		app.l.Info("config reloaded", "file", current.File)
+	}
+}
+
+// restartRequired returns names of changed environment variables that can't be applied at runtime.
+func restartRequired(current, next *config.Config) []string {
+	a, b := *current, *next
+
+	// applied by reloadConfig
+	for _, c := range []*config.Config{&a, &b} {
+		c.Devices = nil
+		c.Z2MDevices = nil
+		c.Alarm.HomeDevices = nil
+		c.Alarm.NightDevices = nil
+		c.Telegram.BotToken, c.Telegram.ChatID = next.Telegram.BotToken, next.Telegram.ChatID
+	}
+
+	return a.Changed(b)
+}
@@ -0,0 +1,171 @@
+package app_test
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	"github.com/SuddenGunter/hsd/app"
+	"github.com/SuddenGunter/hsd/app/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const baseConfig = `
+port: 8080
+mqtt:
+  broker_host: localhost
+  username: hsd
+  password: secret
+`
+
+type fakeDevices struct {
+	mux   sync.Mutex
+	calls [][]string
+}
+
+func (d *fakeDevices) Reconcile(config []alarm.DeviceConfig) error {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	d.calls = append(d.calls, names(config))
+
+	return nil
+}
+
+func names(devices []alarm.DeviceConfig) []string {
+	res := make([]string, 0, len(devices))
+	for _, d := range devices {
+		res = append(res, d.Name)
+	}
+
+	return res
+}
+
+// fakeNotifier fails to reconfigure while broken.
+type fakeNotifier struct {
+	broken bool
+	chats  []int64
+}
+
+func (n *fakeNotifier) Reconfigure(_ string, chatID int64) error {
+	n.chats = append(n.chats, chatID)
+
+	if n.broken {
+		return errors.New("telegram is down")
+	}
+
+	return nil
+}
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func newReloaded(t *testing.T, content string) (*app.App, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "hsd.yaml")
+	writeConfig(t, path, content)
+
+	cfg, err := config.Load(path)
+	require.NoError(t, err)
+
+	return app.New(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg), path
+}
+
+const telegramConfig = `
+telegram:
+  bot_token: "123456:ABC-DEF1234"
+  chat_id: 1
+`
+
+//nolint:paralleltest // Load reads the environment
+func TestReloadConfig_ReconcilesDevices(t *testing.T) {
+	a, path := newReloaded(t, baseConfig+telegramConfig+"devices:\n  - name: door1\n")
+	d, n := &fakeDevices{}, &fakeNotifier{}
+	reload := a.ReloadConfig(d, n)
+
+	writeConfig(t, path, baseConfig+telegramConfig+"devices:\n  - name: door1\n  - name: window1\n")
+	reload()
+
+	writeConfig(t, path, baseConfig+telegramConfig+"devices:\n  - name: window1\n")
+	reload()
+
+	// an invalid config is not applied
+	writeConfig(t, path, baseConfig+telegramConfig+"devices:\n  - name: door1\n    zone: hall\n")
+	reload()
+
+	assert.Equal(t, [][]string{{"door1", "window1"}, {"window1"}}, d.calls)
+	assert.Empty(t, n.chats, "telegram settings did not change")
+}
+
+//nolint:paralleltest // Load reads the environment
+func TestReloadConfig_RetriesTelegram(t *testing.T) {
+	a, path := newReloaded(t, baseConfig+telegramConfig)
+	n := &fakeNotifier{broken: true}
+	reload := a.ReloadConfig(&fakeDevices{}, n)
+
+	writeConfig(t, path, baseConfig+"telegram:\n  bot_token: \"123456:ABC-DEF1234\"\n  chat_id: 2\n")
+	reload()
+	assert.Equal(t, []int64{2}, n.chats)
+
+	// the failed change is applied on the next reload
+	n.broken = false
+	reload()
+	assert.Equal(t, []int64{2, 2}, n.chats)
+
+	reload()
+	assert.Equal(t, []int64{2, 2}, n.chats, "nothing changed since the last reload")
+}
+
+//nolint:paralleltest // Load reads the environment
+func TestRestartRequired(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hsd.yaml")
+	writeConfig(t, path, baseConfig+telegramConfig)
+
+	current, err := config.Load(path)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		change func(c *config.Config)
+		want   []string
+	}{
+		{name: "nothing", change: func(*config.Config) {}},
+		{
+			name: "applied at runtime",
+			change: func(c *config.Config) {
+				c.Devices = []alarm.DeviceConfig{{Name: "door1"}}
+				c.Z2MDevices = []string{"door2"}
+				c.Telegram.BotToken, c.Telegram.ChatID = "654321:FED-CBA4321", 2
+			},
+		},
+		{
+			name: "restart required",
+			change: func(c *config.Config) {
+				c.Port = 9090
+				c.MQTT.Password = "changed"
+				c.Alarm.ArmingDelay = time.Minute
+			},
+			want: []string{"ALARM_ARMING_DELAY", "MQTT_PASSWORD", "PORT"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := *current
+			tt.change(&next)
+
+			assert.Equal(t, tt.want, app.RestartRequired(current, &next))
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/SuddenGunter/hsd/certs"
+)
+
+// serve runs the server until it is shut down, crashing the app if it fails to start.
+func (app *App) serve(srv *http.Server, crash context.CancelFunc) {
+	app.l.Debug("starting http server", "addr", srv.Addr, "tls", srv.TLSConfig != nil)
+
+	var err error
+	if srv.TLSConfig != nil {
+		// certificates are provided by TLSConfig.GetCertificate
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		err = srv.ListenAndServe()
+	}
+
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		app.l.Error("failed to listen and serve", "err", err, "addr", srv.Addr)
+		crash()
+	}
+}
+
+func (app *App) tlsConfig(reloader *certs.Reloader) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if app.cfg.TLS.ClientCAFile != "" {
+		pool, err := certs.LoadCertPool(app.cfg.TLS.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("client ca: %w", err)
+		}
+
+		// client certificates are optional, auth middleware decides which endpoints accept them
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return cfg, nil
+}
+
+// redirectServer returns a plain HTTP server redirecting all requests to HTTPS.
+func (app *App) redirectServer() *http.Server {
+	return &http.Server{
+		ReadTimeout: 5 * time.Second,
+		Addr:        fmt.Sprintf(":%d", app.cfg.TLS.RedirectPort),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host, _, err := net.SplitHostPort(r.Host)
+			if err != nil {
+				host = r.Host
+			}
+
+			if app.cfg.Port != 443 {
+				host = net.JoinHostPort(host, fmt.Sprint(app.cfg.Port))
+			}
+
+			http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+		}),
+	}
+}
+
+// onSIGHUP calls fn on every SIGHUP until ctx is done.
+func (app *App) onSIGHUP(ctx context.Context, fn func()) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			app.l.Info("SIGHUP received")
+			fn()
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Package audit writes an append-only log of alarm state change requests.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Actions recorded in the audit log.
+const (
+	ActionArm     = "arm"
+	ActionDisarm  = "disarm"
+	ActionTrigger = "trigger"
+)
+
+// Results of audited actions.
+const (
+	ResultOK       = "ok"
+	ResultRejected = "rejected"
+)
+
+// Entry is a single audit log record.
+type Entry struct {
+	Time time.Time `json:"time"`
+	// Actor is the name of the caller, empty if the source has no notion of users (e.g. Home Assistant).
+	Actor  string `json:"actor,omitempty"`
+	Source string `json:"source"`
+	Action string `json:"action"`
+	Mode   string `json:"mode,omitempty"`
+	From   string `json:"from"`
+	To     string `json:"to,omitempty"`
+	// Bypassed lists devices bypassed by a forced arm.
+	Bypassed []string `json:"bypassed,omitempty"`
+	Result   string   `json:"result"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// Log appends entries to a JSON lines file. The file is never rewritten or pruned.
+type Log struct {
+	mux  *sync.Mutex
+	file *os.File
+
+	l *slog.Logger
+}
+
+// Open opens the audit log at path for appending. Empty path only logs entries with l.
+func Open(path string, l *slog.Logger) (*Log, error) {
+	a := &Log{mux: &sync.Mutex{}, l: l}
+
+	if path == "" {
+		return a, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("audit: open: %w", err)
+	}
+
+	a.file = f
+
+	return a, nil
+}
+
+// Audit records the entry.
+func (a *Log) Audit(e Entry) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	a.l.Info("alarm audit",
+		"actor", e.Actor, "source", e.Source, "action", e.Action, "mode", e.Mode,
+		"from", e.From, "to", e.To, "result", e.Result, "err", e.Error)
+
+	if a.file == nil {
+		return
+	}
+
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		a.l.Error("failed to marshal audit entry", "err", err)
+		return
+	}
+
+	// a single write per entry, so concurrent writers never interleave lines
+	if _, err := a.file.Write(append(line, '\n')); err != nil {
+		a.l.Error("failed to write audit entry", "err", err)
+		return
+	}
+
+	if err := a.file.Sync(); err != nil {
+		a.l.Error("failed to sync audit log", "err", err)
+	}
+}
+
+// Close closes the underlying file.
+func (a *Log) Close() error {
+	if a.file == nil {
+		return nil
+	}
+
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	if err := a.file.Close(); err != nil {
+		return fmt.Errorf("audit: close: %w", err)
+	}
+
+	return nil
+}
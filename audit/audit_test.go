@@ -0,0 +1,50 @@
+package audit_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SuddenGunter/hsd/audit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLog_AppendsEntries(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// entries are appended across restarts
+	for _, actor := range []string{"alice", "bob"} {
+		a, err := audit.Open(path, l)
+		require.NoError(t, err)
+
+		a.Audit(audit.Entry{Actor: actor, Source: "api", Action: audit.ActionDisarm, Result: audit.ResultOK})
+		require.NoError(t, a.Close())
+	}
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+
+	defer f.Close()
+
+	var actors []string
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e audit.Entry
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &e))
+		assert.False(t, e.Time.IsZero())
+
+		actors = append(actors, e.Actor)
+	}
+
+	require.NoError(t, sc.Err())
+	assert.Equal(t, []string{"alice", "bob"}, actors)
+}
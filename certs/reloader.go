@@ -0,0 +1,157 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrNoCertificates is returned when the client CA file has no PEM certificates.
+var ErrNoCertificates = errors.New("no certificates found")
+
+// Reloader serves a TLS certificate and reloads it when the certificate or key file changes.
+// Files are checked for changes periodically, Reload can be called to force a reload (e.g. on SIGHUP).
+type Reloader struct {
+	certFile string
+	keyFile  string
+
+	mux     *sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+
+	close chan struct{}
+	wg    *sync.WaitGroup
+
+	l *slog.Logger
+}
+
+// NewReloader loads the certificate and starts watching the files for changes.
+func NewReloader(certFile, keyFile string, interval time.Duration, l *slog.Logger) (*Reloader, error) {
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		mux:      &sync.RWMutex{},
+		close:    make(chan struct{}),
+		wg:       &sync.WaitGroup{},
+		l:        l,
+	}
+
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	r.wg.Add(1)
+
+	go r.watch(interval)
+
+	return r, nil
+}
+
+// GetCertificate returns the current certificate, to be used as tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	return r.cert, nil
+}
+
+// Reload loads the certificate from disk. On error, the previous certificate is kept.
+func (r *Reloader) Reload() error {
+	modTime, err := r.lastModified()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("certs: load: %w", err)
+	}
+
+	r.mux.Lock()
+	r.cert = &cert
+	r.modTime = modTime
+	r.mux.Unlock()
+
+	r.l.Info("tls certificate loaded", "cert", r.certFile)
+
+	return nil
+}
+
+// Close stops watching the files.
+func (r *Reloader) Close() {
+	close(r.close)
+	r.wg.Wait()
+}
+
+func (r *Reloader) watch(interval time.Duration) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.close:
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+
+			if err := r.Reload(); err != nil {
+				r.l.Error("failed to reload tls certificate", "err", err)
+			}
+		}
+	}
+}
+
+func (r *Reloader) changed() bool {
+	modTime, err := r.lastModified()
+	if err != nil {
+		r.l.Error("failed to check tls certificate", "err", err)
+		return false
+	}
+
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	return !modTime.Equal(r.modTime)
+}
+
+// lastModified returns the latest modification time of the certificate and key files.
+func (r *Reloader) lastModified() (time.Time, error) {
+	var latest time.Time
+
+	for _, f := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(f)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("certs: %w", err)
+		}
+
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
+
+// LoadCertPool loads PEM certificates from the file.
+func LoadCertPool(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("certs: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("certs: %s: %w", file, ErrNoCertificates)
+	}
+
+	return pool, nil
+}
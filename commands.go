@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime/debug"
+	"strings"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	"github.com/SuddenGunter/hsd/app"
+	"github.com/SuddenGunter/hsd/app/config"
+	"github.com/SuddenGunter/hsd/exitcode"
+	"github.com/SuddenGunter/hsd/notify/message"
+	"github.com/SuddenGunter/hsd/telegram"
+)
+
+// buildVersion is set at build time with -ldflags "-X main.buildVersion=v1.2.3".
+var buildVersion = ""
+
+// version returns the build version, falling back to the module version and the VCS revision.
+func version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return buildVersion
+	}
+
+	v := buildVersion
+	if v == "" {
+		v = info.Main.Version
+	}
+
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" {
+			v += " (" + s.Value + ")"
+		}
+	}
+
+	return v + " " + info.GoVersion
+}
+
+func printVersion(args []string) int {
+	parseFlags("version", args)
+
+	fmt.Println("hsd", version())
+
+	return 0
+}
+
+func checkConfig(args []string) int {
+	opts := parseFlags("check-config", args)
+
+	cfg, code, err := opts.loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return code
+	}
+
+	if cfg.File != "" {
+		fmt.Printf("# %s\n", cfg.File)
+	}
+
+	for _, v := range cfg.Environ() {
+		fmt.Println(v)
+	}
+
+	if len(cfg.Devices) > 0 {
+		fmt.Println("# devices")
+
+		for _, d := range cfg.Devices {
+			fmt.Printf("%s: %s\n", d.Name, modes(d.Modes))
+		}
+	}
+
+	fmt.Fprintln(os.Stderr, "config is valid")
+
+	return 0
+}
+
+// sender delivers a message and reports the delivery error.
+type sender interface {
+	Send(m message.Message) error
+}
+
+func testNotify(args []string) int {
+	opts := parseFlags("test-notify", args)
+	l := opts.logger()
+
+	cfg, code, err := opts.loadConfig()
+	if err != nil {
+		l.Error("failed to load config", "err", err)
+		return code
+	}
+
+	notifiers, err := newNotifiers(cfg, l)
+	if err != nil {
+		l.Error("failed to create notifiers", "err", err)
+		return exitcode.Notify
+	}
+
+	host, _ := os.Hostname()
+	msg := message.New(message.KeyTest, message.Info, "hsd", "host", host, "version", version())
+	code = 0
+
+	for name, n := range notifiers {
+		if err := n.Send(msg); err != nil {
+			l.Error("test notification failed", "notifier", name, "err", err)
+
+			code = exitcode.Notify
+
+			continue
+		}
+
+		l.Info("test notification sent", "notifier", name)
+	}
+
+	return code
+}
+
+// newNotifiers returns configured notifiers by name.
+func newNotifiers(cfg *config.Config, l *slog.Logger) (map[string]sender, error) {
+	opts, err := app.TelegramOptions(cfg, nil)
+	if err != nil {
+		return nil, fmt.Errorf("telegram: %w", err)
+	}
+
+	tg, err := telegram.NewNotifier(cfg.Telegram.BotToken, cfg.Telegram.ChatID, opts, l)
+	if err != nil {
+		return nil, fmt.Errorf("telegram: %w", err)
+	}
+
+	return map[string]sender{"telegram": tg}, nil
+}
+
+func modes(ms []alarm.Mode) string {
+	if len(ms) == 0 {
+		return "all modes"
+	}
+
+	s := make([]string, 0, len(ms))
+	for _, m := range ms {
+		s = append(s, string(m))
+	}
+
+	return strings.Join(s, ", ")
+}
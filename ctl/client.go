@@ -0,0 +1,199 @@
+package ctl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	"github.com/SuddenGunter/hsd/api/httpjson"
+	"github.com/SuddenGunter/hsd/event"
+)
+
+// APIError is returned when hsd responds with an error status.
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, http.StatusText(e.Status), e.Message)
+}
+
+// AlarmStatus is the response of GET /alarm.
+type AlarmStatus struct {
+	Enabled bool               `json:"enabled"`
+	State   alarm.State        `json:"state"`
+	Mode    alarm.Mode         `json:"mode"`
+	History []alarm.Transition `json:"history"`
+}
+
+// EventQuery filters events returned by Client.Events. Zero fields are not sent.
+type EventQuery struct {
+	Device string
+	Type   event.Type
+	Since  time.Time
+	Limit  int
+	// Before is the Next value of the previous page.
+	Before uint64
+}
+
+// Client is a client of the hsd HTTP API.
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// NewClient returns a new Client. Requests are authenticated with the bearer token, if set.
+func NewClient(baseURL, token string, httpClient *http.Client) *Client {
+	return &Client{baseURL: strings.TrimSuffix(baseURL, "/"), token: token, http: httpClient}
+}
+
+// Status returns the alarm status.
+func (c *Client) Status(ctx context.Context) (AlarmStatus, error) {
+	var s AlarmStatus
+
+	err := c.do(ctx, http.MethodGet, "/alarm", nil, &s)
+
+	return s, err
+}
+
+// Arm arms the alarm in the mode, empty mode defaults to away.
+// With force, open or offline devices are bypassed instead of refusing to arm.
+func (c *Client) Arm(ctx context.Context, mode alarm.Mode, force bool) error {
+	return c.do(ctx, http.MethodPost, "/alarm", map[string]any{"enabled": true, "mode": mode, "force": force}, nil)
+}
+
+// Disarm disarms the alarm.
+func (c *Client) Disarm(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/alarm", map[string]any{"enabled": false}, nil)
+}
+
+// Devices returns statuses of all devices.
+func (c *Client) Devices(ctx context.Context) ([]alarm.DeviceStatus, error) {
+	var devices []alarm.DeviceStatus
+
+	err := c.do(ctx, http.MethodGet, "/devices", nil, &devices)
+
+	return devices, err
+}
+
+// Bypass excludes the device from alarms for the duration, or until the alarm is disarmed if duration is 0.
+func (c *Client) Bypass(ctx context.Context, device string, duration time.Duration, reason string) (alarm.DeviceStatus, error) {
+	req := map[string]any{"reason": reason}
+	if duration > 0 {
+		req["duration"] = duration.String()
+	} else {
+		req["untilDisarm"] = true
+	}
+
+	var s alarm.DeviceStatus
+
+	err := c.do(ctx, http.MethodPut, "/devices/"+url.PathEscape(device)+"/bypass", req, &s)
+
+	return s, err
+}
+
+// EndBypass reinstates the device.
+func (c *Client) EndBypass(ctx context.Context, device string) (alarm.DeviceStatus, error) {
+	var s alarm.DeviceStatus
+
+	err := c.do(ctx, http.MethodDelete, "/devices/"+url.PathEscape(device)+"/bypass", nil, &s)
+
+	return s, err
+}
+
+// Events returns a page of events matching the query, newest first.
+func (c *Client) Events(ctx context.Context, q EventQuery) (event.Page, error) {
+	v := url.Values{}
+
+	if q.Device != "" {
+		v.Set("device", q.Device)
+	}
+
+	if q.Type != "" {
+		v.Set("type", string(q.Type))
+	}
+
+	if !q.Since.IsZero() {
+		v.Set("since", q.Since.Format(time.RFC3339))
+	}
+
+	if q.Limit > 0 {
+		v.Set("limit", strconv.Itoa(q.Limit))
+	}
+
+	if q.Before > 0 {
+		v.Set("before", strconv.FormatUint(q.Before, 10))
+	}
+
+	path := "/events"
+	if len(v) > 0 {
+		path += "?" + v.Encode()
+	}
+
+	var p event.Page
+
+	err := c.do(ctx, http.MethodGet, path, nil, &p)
+
+	return p, err
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, v any) error {
+	var r io.Reader
+
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", method, path, err)
+		}
+
+		r = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, r)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var e httpjson.ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&e); err != nil || e.Error == "" {
+			e.Error = http.StatusText(resp.StatusCode)
+		}
+
+		return &APIError{Status: resp.StatusCode, Message: e.Error}
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%s %s: decode response: %w", method, path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,306 @@
+// Package ctl implements hsd ctl, a command line client of the hsd HTTP API.
+package ctl
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	"github.com/SuddenGunter/hsd/event"
+	"github.com/SuddenGunter/hsd/exitcode"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+var errUsage = errors.New("usage")
+
+// session holds flags shared by all ctl commands and the command output.
+type session struct {
+	fs      *flag.FlagSet
+	out     io.Writer
+	url     string
+	token   string
+	output  string
+	timeout time.Duration
+}
+
+// command is a ctl command. It parses its own flags from args.
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, s *session, args []string) error
+}
+
+func commands() []command {
+	return []command{
+		{name: "status", usage: "show the alarm state", run: status},
+		{name: "arm", usage: "arm the alarm: arm [-mode home|away|night] [-force]", run: arm},
+		{name: "disarm", usage: "disarm the alarm", run: disarm},
+		{name: "devices", usage: "list devices", run: devices},
+		{name: "mute", usage: "bypass a device: mute [-reason text] <device> <duration|disarm>", run: mute},
+		{name: "unmute", usage: "reinstate a bypassed device: unmute <device>", run: unmute},
+		{name: "events", usage: "list events: events [-since 1h] [-device name] [-type type] [-limit n]", run: events},
+	}
+}
+
+// Run runs the ctl command in args and returns the OS exit code.
+func Run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		usage(stderr)
+		return exitcode.Usage
+	}
+
+	name, args := args[0], args[1:]
+
+	for _, cmd := range commands() {
+		if cmd.name != name {
+			continue
+		}
+
+		s := &session{fs: flag.NewFlagSet("ctl "+name, flag.ContinueOnError), out: stdout}
+		s.fs.SetOutput(stderr)
+		s.fs.StringVar(&s.url, "url", envOr("HSD_URL", "http://localhost:8080"), "hsd base URL, defaults to HSD_URL")
+		s.fs.StringVar(&s.token, "token", os.Getenv("HSD_TOKEN"), "API bearer token, defaults to HSD_TOKEN")
+		s.fs.StringVar(&s.output, "o", outputTable, "output format: table or json")
+		s.fs.DurationVar(&s.timeout, "timeout", 10*time.Second, "request timeout")
+
+		err := cmd.run(ctx, s, args)
+
+		switch {
+		case err == nil:
+			return 0
+		case errors.Is(err, errUsage), errors.Is(err, flag.ErrHelp):
+			return exitcode.Usage
+		default:
+			fmt.Fprintf(stderr, "ctl %s: %v\n", name, err)
+			return exitcode.Request
+		}
+	}
+
+	fmt.Fprintf(stderr, "unknown ctl command %q\n\n", name)
+	usage(stderr)
+
+	return exitcode.Usage
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: hsd ctl <command> [flags]\n\nCommands:\n")
+
+	for _, cmd := range commands() {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.usage)
+	}
+
+	fmt.Fprintf(w, "\nCommon flags: -url, -token, -o table|json, -timeout. Run hsd ctl <command> -h for details.\n")
+}
+
+// parse parses command flags, checks the number of positional arguments and returns the API client.
+func (s *session) parse(args []string, nargs int) (*Client, error) {
+	if err := s.fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if s.fs.NArg() != nargs {
+		fmt.Fprintf(s.fs.Output(), "%s: expected %d arguments, got %d\n", s.fs.Name(), nargs, s.fs.NArg())
+		return nil, errUsage
+	}
+
+	if s.output != outputTable && s.output != outputJSON {
+		fmt.Fprintf(s.fs.Output(), "%s: unknown output format %q\n", s.fs.Name(), s.output)
+		return nil, errUsage
+	}
+
+	return NewClient(s.url, s.token, &http.Client{Timeout: s.timeout}), nil
+}
+
+func status(ctx context.Context, s *session, args []string) error {
+	c, err := s.parse(args, 0)
+	if err != nil {
+		return err
+	}
+
+	st, err := c.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.printStatus(st)
+}
+
+func arm(ctx context.Context, s *session, args []string) error {
+	mode := s.fs.String("mode", string(alarm.ModeAway), "arm mode: home, away or night")
+	force := s.fs.Bool("force", false, "arm even if devices are open or offline, bypassing them until disarmed")
+
+	c, err := s.parse(args, 0)
+	if err != nil {
+		return err
+	}
+
+	m, err := alarm.ParseMode(*mode)
+	if err != nil {
+		return err
+	}
+
+	if err := c.Arm(ctx, m, *force); err != nil {
+		return err
+	}
+
+	st, err := c.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.printStatus(st)
+}
+
+func disarm(ctx context.Context, s *session, args []string) error {
+	c, err := s.parse(args, 0)
+	if err != nil {
+		return err
+	}
+
+	if err := c.Disarm(ctx); err != nil {
+		return err
+	}
+
+	st, err := c.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.printStatus(st)
+}
+
+func devices(ctx context.Context, s *session, args []string) error {
+	c, err := s.parse(args, 0)
+	if err != nil {
+		return err
+	}
+
+	d, err := c.Devices(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.printDevices(d)
+}
+
+func mute(ctx context.Context, s *session, args []string) error {
+	reason := s.fs.String("reason", "", "why the device is bypassed")
+
+	c, err := s.parse(args, 2)
+	if err != nil {
+		return err
+	}
+
+	// 0 bypasses the device until the alarm is disarmed
+	var d time.Duration
+
+	if v := s.fs.Arg(1); v != "disarm" {
+		if d, err = time.ParseDuration(v); err != nil || d <= 0 {
+			return fmt.Errorf("invalid duration %q: expected a positive duration, e.g. 30m, or disarm", v)
+		}
+	}
+
+	st, err := c.Bypass(ctx, s.fs.Arg(0), d, *reason)
+	if err != nil {
+		return err
+	}
+
+	return s.printDevices([]alarm.DeviceStatus{st})
+}
+
+func unmute(ctx context.Context, s *session, args []string) error {
+	c, err := s.parse(args, 1)
+	if err != nil {
+		return err
+	}
+
+	st, err := c.EndBypass(ctx, s.fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	return s.printDevices([]alarm.DeviceStatus{st})
+}
+
+func events(ctx context.Context, s *session, args []string) error {
+	var q EventQuery
+
+	since := s.fs.String("since", "", "only events newer than a duration (e.g. 1h) or an RFC 3339 time")
+	s.fs.StringVar(&q.Device, "device", "", "only events of the device")
+	s.fs.Func("type", "only events of the type: device_update, availability, alarm, state_change or bypass", func(v string) error {
+		q.Type = event.Type(v)
+		return nil
+	})
+	s.fs.IntVar(&q.Limit, "limit", 0, "maximum number of events, all matching events if 0")
+
+	c, err := s.parse(args, 0)
+	if err != nil {
+		return err
+	}
+
+	if *since != "" {
+		if q.Since, err = parseSince(*since, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	var all []event.Event
+
+	// the server returns at most event.MaxLimit events per page
+	for {
+		page := q
+		page.Limit = event.MaxLimit
+
+		if q.Limit > 0 {
+			page.Limit = min(q.Limit-len(all), event.MaxLimit)
+		}
+
+		p, err := c.Events(ctx, page)
+		if err != nil {
+			return err
+		}
+
+		all = append(all, p.Events...)
+
+		if p.Next == 0 || (q.Limit > 0 && len(all) >= q.Limit) {
+			break
+		}
+
+		q.Before = p.Next
+	}
+
+	return s.printEvents(all)
+}
+
+// parseSince parses a duration relative to now or an RFC 3339 time.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid since %q: expected a duration or an RFC 3339 time", s)
+	}
+
+	return t, nil
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+
+	return def
+}
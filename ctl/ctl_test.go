@@ -0,0 +1,244 @@
+package ctl_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	eventsgethandler "github.com/SuddenGunter/hsd/api/events/get"
+	"github.com/SuddenGunter/hsd/ctl"
+	"github.com/SuddenGunter/hsd/event"
+	"github.com/SuddenGunter/hsd/exitcode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeAPI struct {
+	armed   bool
+	mode    string
+	queries []string
+	bodies  []map[string]any
+	events  *event.Store
+}
+
+func (api *fakeAPI) handler(t *testing.T) http.Handler {
+	t.Helper()
+
+	mux := http.NewServeMux()
+
+	auth := func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(`{"status":401,"error":"unauthorized"}`))
+
+				return
+			}
+
+			h(w, r)
+		}
+	}
+
+	mux.HandleFunc("GET /alarm", auth(func(w http.ResponseWriter, _ *http.Request) {
+		state := "disarmed"
+		if api.armed {
+			state = "armed_" + api.mode
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"enabled": api.armed,
+			"state":   state,
+			"mode":    api.mode,
+			"history": []map[string]any{{"to": state, "actor": "alice", "source": "api", "time": time.Now()}},
+		})
+	}))
+	mux.HandleFunc("POST /alarm", auth(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		api.bodies = append(api.bodies, body)
+		api.armed = body["enabled"].(bool)
+		api.mode, _ = body["mode"].(string)
+
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	mux.HandleFunc("PUT /devices/{name}/bypass", auth(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		api.bodies = append(api.bodies, body)
+
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"name":      r.PathValue("name"),
+			"available": true,
+			"bypass":    map[string]any{"until": nil, "source": "api"},
+		})
+	}))
+	if api.events == nil {
+		store, err := event.Open("", 0, 0, slog.New(slog.DiscardHandler))
+		require.NoError(t, err)
+		t.Cleanup(func() { store.Close() })
+
+		api.events = store
+	}
+
+	events := eventsgethandler.NewGetHandler(slog.New(slog.DiscardHandler), api.events)
+
+	mux.HandleFunc("GET /events", auth(func(w http.ResponseWriter, r *http.Request) {
+		api.queries = append(api.queries, r.URL.RawQuery)
+
+		events.ServeHTTP(w, r)
+	}))
+
+	return mux
+}
+
+func run(t *testing.T, srv *httptest.Server, args ...string) (int, string, string) {
+	t.Helper()
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	args = append(args[:1:1], append([]string{"-url", srv.URL, "-token", "secret"}, args[1:]...)...)
+
+	code := ctl.Run(context.Background(), args, stdout, stderr)
+
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun_ArmAndStatus(t *testing.T) {
+	t.Parallel()
+
+	api := &fakeAPI{}
+	srv := httptest.NewServer(api.handler(t))
+	t.Cleanup(srv.Close)
+
+	code, out, _ := run(t, srv, "arm", "-mode", "night")
+
+	require.Equal(t, 0, code)
+	assert.Equal(t, []map[string]any{{"enabled": true, "mode": "night", "force": false}}, api.bodies)
+	assert.Contains(t, out, "armed_night")
+	assert.Contains(t, out, "alice via api")
+
+	code, out, _ = run(t, srv, "status", "-o", "json")
+
+	require.Equal(t, 0, code)
+
+	var st ctl.AlarmStatus
+	require.NoError(t, json.Unmarshal([]byte(out), &st))
+	assert.True(t, st.Enabled)
+	assert.Equal(t, "armed_night", string(st.State))
+}
+
+func TestRun_Events(t *testing.T) {
+	t.Parallel()
+
+	api := &fakeAPI{}
+	srv := httptest.NewServer(api.handler(t))
+	t.Cleanup(srv.Close)
+
+	opened := true
+	api.events.Record(event.Event{Type: event.TypeDeviceUpdate, Device: "door1", Opened: &opened, Time: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)})
+	api.events.Record(event.Event{Type: event.TypeDeviceUpdate, Device: "door2", Opened: &opened, Time: time.Date(2026, 1, 2, 3, 4, 6, 0, time.UTC)})
+
+	code, out, _ := run(t, srv, "events", "-since", "2026-01-01T00:00:00Z", "-device", "door1", "-limit", "5")
+
+	require.Equal(t, 0, code)
+	assert.Equal(t, []string{"device=door1&limit=5&since=2026-01-01T00%3A00%3A00Z"}, api.queries)
+	assert.Contains(t, out, "device_update")
+	assert.Contains(t, out, "opened=true")
+	assert.NotContains(t, out, "door2")
+}
+
+func TestRun_EventsPages(t *testing.T) {
+	t.Parallel()
+
+	api := &fakeAPI{}
+	srv := httptest.NewServer(api.handler(t))
+	t.Cleanup(srv.Close)
+
+	for range 1500 {
+		api.events.Record(event.Event{Type: event.TypeAlarm, Device: "door1"})
+	}
+
+	code, out, _ := run(t, srv, "events", "-limit", "1200", "-o", "json")
+
+	require.Equal(t, 0, code)
+	assert.Equal(t, []string{"limit=1000", "before=501&limit=200"}, api.queries)
+
+	var events []event.Event
+	require.NoError(t, json.Unmarshal([]byte(out), &events))
+	require.Len(t, events, 1200)
+	assert.Equal(t, uint64(1500), events[0].ID)
+	assert.Equal(t, uint64(301), events[1199].ID)
+
+	// without a limit all pages are followed
+	api.queries = nil
+	code, out, _ = run(t, srv, "events", "-o", "json")
+
+	require.Equal(t, 0, code)
+	assert.Equal(t, []string{"limit=1000", "before=501&limit=1000"}, api.queries)
+	require.NoError(t, json.Unmarshal([]byte(out), &events))
+	assert.Len(t, events, 1500)
+}
+
+func TestRun_Mute(t *testing.T) {
+	t.Parallel()
+
+	api := &fakeAPI{}
+	srv := httptest.NewServer(api.handler(t))
+	t.Cleanup(srv.Close)
+
+	code, _, _ := run(t, srv, "mute", "-reason", "faulty", "window1", "30m")
+	require.Equal(t, 0, code)
+
+	code, out, _ := run(t, srv, "mute", "window1", "disarm")
+	require.Equal(t, 0, code)
+
+	assert.Equal(t, []map[string]any{
+		{"duration": "30m0s", "reason": "faulty"},
+		{"untilDisarm": true, "reason": ""},
+	}, api.bodies)
+	assert.Contains(t, out, "until disarmed")
+
+	code, _, _ = run(t, srv, "mute", "window1", "forever")
+	assert.Equal(t, exitcode.Request, code)
+
+	code, _, _ = run(t, srv, "mute", "window1")
+	assert.Equal(t, exitcode.Usage, code)
+}
+
+func TestRun_Errors(t *testing.T) {
+	t.Parallel()
+
+	api := &fakeAPI{}
+	srv := httptest.NewServer(api.handler(t))
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+		err  string
+	}{
+		{name: "unauthorized", args: []string{"status", "-token", "wrong"}, code: exitcode.Request, err: "401 Unauthorized: unauthorized"},
+		{name: "unknown mode", args: []string{"arm", "-mode", "vacation"}, code: exitcode.Request, err: "unknown arm mode"},
+		{name: "unexpected argument", args: []string{"disarm", "now"}, code: exitcode.Usage},
+		{name: "unknown output", args: []string{"devices", "-o", "xml"}, code: exitcode.Usage},
+		{name: "invalid since", args: []string{"events", "-since", "yesterday"}, code: exitcode.Request, err: "invalid since"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			code, _, stderr := run(t, srv, tt.args...)
+
+			assert.Equal(t, tt.code, code)
+			assert.Contains(t, stderr, tt.err)
+		})
+	}
+}
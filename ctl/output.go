@@ -0,0 +1,136 @@
+package ctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	"github.com/SuddenGunter/hsd/event"
+)
+
+const timeFormat = time.DateTime
+
+func (s *session) printStatus(st AlarmStatus) error {
+	if s.output == outputJSON {
+		return s.printJSON(st)
+	}
+
+	return s.table(func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "STATE\tMODE\tSINCE\tBY\n")
+
+		since, by := "-", "-"
+
+		if n := len(st.History); n > 0 {
+			last := st.History[n-1]
+			since = last.Time.Local().Format(timeFormat)
+			by = alarm.Actor{Name: last.Actor, Source: last.Source}.String()
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", st.State, orDash(string(st.Mode)), since, by)
+	})
+}
+
+func (s *session) printDevices(devices []alarm.DeviceStatus) error {
+	if s.output == outputJSON {
+		return s.printJSON(devices)
+	}
+
+	return s.table(func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "NAME\tAVAILABLE\tOPENED\tBATTERY\tLINK QUALITY\tLAST UPDATED\tBYPASSED\n")
+
+		for _, d := range devices {
+			updated := "-"
+			if d.LastUpdated != nil {
+				updated = d.LastUpdated.Local().Format(timeFormat)
+			}
+
+			fmt.Fprintf(w, "%s\t%t\t%t\t%d%%\t%d\t%s\t%s\n", d.Name, d.Available, d.Opened, d.Battery, d.LinkQuality, updated, bypassed(d.Bypass))
+		}
+	})
+}
+
+func (s *session) printEvents(events []event.Event) error {
+	if s.output == outputJSON {
+		return s.printJSON(events)
+	}
+
+	return s.table(func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "ID\tTIME\tTYPE\tDEVICE\tDETAILS\n")
+
+		for _, e := range events {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", e.ID, e.Time.Local().Format(timeFormat), e.Type, orDash(e.Device), details(e))
+		}
+	})
+}
+
+func bypassed(b *alarm.Bypass) string {
+	switch {
+	case b == nil:
+		return "-"
+	case b.Until == nil:
+		return "until disarmed"
+	default:
+		return "until " + b.Until.Local().Format(timeFormat)
+	}
+}
+
+// details summarizes event fields that depend on the event type.
+func details(e event.Event) string {
+	var parts []string
+
+	if e.Opened != nil {
+		parts = append(parts, "opened="+strconv.FormatBool(*e.Opened))
+	}
+
+	if e.Available != nil {
+		parts = append(parts, "available="+strconv.FormatBool(*e.Available))
+	}
+
+	if e.State != "" {
+		parts = append(parts, "state="+e.State)
+	}
+
+	if e.Actor != "" || e.Source != "" {
+		parts = append(parts, "by="+alarm.Actor{Name: e.Actor, Source: e.Source}.String())
+	}
+
+	if e.Message != "" {
+		parts = append(parts, e.Message)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func (s *session) table(write func(w *tabwriter.Writer)) error {
+	w := tabwriter.NewWriter(s.out, 0, 0, 2, ' ', 0)
+	write(w)
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("write output: %w", err)
+	}
+
+	return nil
+}
+
+func (s *session) printJSON(v any) error {
+	enc := json.NewEncoder(s.out)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("write output: %w", err)
+	}
+
+	return nil
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
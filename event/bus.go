@@ -0,0 +1,104 @@
+package event
+
+import (
+	"sync"
+)
+
+// Bus stores events and fans them out to subscribers.
+// Slow subscribers don't block publishers: when a subscriber's buffer is full, it is dropped and its channel closed.
+type Bus struct {
+	store *Store
+
+	mux    *sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// Subscription receives events published to the Bus.
+type Subscription struct {
+	c    chan Event
+	bus  *Bus
+	once *sync.Once
+}
+
+// NewBus returns a new Bus backed by the store.
+func NewBus(store *Store) *Bus {
+	return &Bus{
+		store: store,
+		mux:   &sync.Mutex{},
+		subs:  make(map[*Subscription]struct{}),
+	}
+}
+
+// Record stores the event and publishes it to subscribers.
+func (b *Bus) Record(e Event) {
+	// store under the bus lock, so subscribers receive events in ID order
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	e = b.store.Record(e)
+
+	for s := range b.subs {
+		select {
+		case s.c <- e:
+		default:
+			// subscriber can't keep up, it has to resubscribe and catch up from the store
+			b.unsubscribe(s)
+		}
+	}
+}
+
+// Subscribe returns a subscription buffering up to buffer events.
+// The subscription channel is closed if the subscriber falls behind or the bus is closed.
+func (b *Bus) Subscribe(buffer int) *Subscription {
+	s := &Subscription{c: make(chan Event, buffer), bus: b, once: &sync.Once{}}
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if b.closed {
+		close(s.c)
+		return s
+	}
+
+	b.subs[s] = struct{}{}
+
+	return s
+}
+
+// Close closes all subscriptions. Events are still stored after Close.
+func (b *Bus) Close() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.closed = true
+
+	for s := range b.subs {
+		b.unsubscribe(s)
+	}
+}
+
+// unsubscribe must be called with b.mux held.
+func (b *Bus) unsubscribe(s *Subscription) {
+	if _, ok := b.subs[s]; !ok {
+		return
+	}
+
+	delete(b.subs, s)
+	close(s.c)
+}
+
+// C returns the channel events are delivered to.
+func (s *Subscription) C() <-chan Event {
+	return s.c
+}
+
+// Close stops the subscription.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.bus.mux.Lock()
+		defer s.bus.mux.Unlock()
+
+		s.bus.unsubscribe(s)
+	})
+}
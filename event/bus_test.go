@@ -0,0 +1,55 @@
+package event_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SuddenGunter/hsd/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus_PublishesStoredEvents(t *testing.T) {
+	t.Parallel()
+
+	s, err := event.Open("", time.Hour, 100, discard())
+	require.NoError(t, err)
+
+	defer s.Close()
+
+	b := event.NewBus(s)
+	sub := b.Subscribe(1)
+
+	b.Record(event.Event{Type: event.TypeAlarm, Device: "door1"})
+
+	e := <-sub.C()
+	assert.Equal(t, uint64(1), e.ID)
+	assert.Equal(t, "door1", e.Device)
+	assert.Len(t, s.After(0, event.Filter{}), 1)
+}
+
+func TestBus_DropsSlowSubscriber(t *testing.T) {
+	t.Parallel()
+
+	s, err := event.Open("", time.Hour, 100, discard())
+	require.NoError(t, err)
+
+	defer s.Close()
+
+	b := event.NewBus(s)
+	sub := b.Subscribe(1)
+
+	b.Record(event.Event{Type: event.TypeAlarm})
+	b.Record(event.Event{Type: event.TypeAlarm})
+
+	_, ok := <-sub.C()
+	assert.True(t, ok)
+
+	_, ok = <-sub.C()
+	assert.False(t, ok, "subscription should be closed")
+
+	// publishing is not blocked by the dropped subscriber
+	b.Record(event.Event{Type: event.TypeAlarm})
+	assert.Len(t, s.After(0, event.Filter{}), 3)
+	sub.Close()
+}
@@ -0,0 +1,38 @@
+package event
+
+import "time"
+
+// Type of the event.
+type Type string
+
+// Supported event types.
+const (
+	// TypeDeviceUpdate is recorded when a device is opened or closed.
+	TypeDeviceUpdate Type = "device_update"
+	// TypeAvailability is recorded when a device goes online or offline.
+	TypeAvailability Type = "availability"
+	// TypeAlarm is recorded when an alarm is sent to the notifier.
+	TypeAlarm Type = "alarm"
+	// TypeStateChange is recorded when the alarm is armed, disarmed or goes off.
+	TypeStateChange Type = "state_change"
+	// TypeBypass is recorded when a device is bypassed or reinstated.
+	TypeBypass Type = "bypass"
+)
+
+// Event is something hsd observed or did.
+type Event struct {
+	ID   uint64    `json:"id"`
+	Time time.Time `json:"time"`
+	Type Type      `json:"type"`
+
+	Device string `json:"device,omitempty"`
+	// Actor is the user or device that caused a state change, if known.
+	Actor string `json:"actor,omitempty"`
+	// Source is where the state change came from, e.g. api or homeassistant.
+	Source  string `json:"source,omitempty"`
+	Message string `json:"message,omitempty"`
+
+	Opened    *bool  `json:"opened,omitempty"`
+	Available *bool  `json:"available,omitempty"`
+	State     string `json:"state,omitempty"`
+}
@@ -0,0 +1,101 @@
+package event
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// MaxLimit is the maximum number of events returned by a single query.
+const MaxLimit = 1000
+
+// Filter selects events in Query. Zero values match all events.
+type Filter struct {
+	Device string
+	Type   Type
+	Since  time.Time
+	Until  time.Time
+	// Before returns only events with ID lower than Before, used for pagination.
+	Before uint64
+	Limit  int
+}
+
+// Page is a result of Query.
+type Page struct {
+	Events []Event `json:"events"`
+	// Next is the Before value for the next page, 0 if there are no more events.
+	Next uint64 `json:"next,omitempty"`
+}
+
+// Query returns events matching the filter, newest first.
+func (s *Store) Query(f Filter) Page {
+	if f.Limit <= 0 || f.Limit > MaxLimit {
+		f.Limit = MaxLimit
+	}
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	p := Page{Events: make([]Event, 0, min(f.Limit, len(s.events)))}
+
+	for i := len(s.events) - 1; i >= 0; i-- {
+		e := s.events[i]
+		if !f.Match(e) {
+			continue
+		}
+
+		if len(p.Events) == f.Limit {
+			p.Next = p.Events[len(p.Events)-1].ID
+			break
+		}
+
+		p.Events = append(p.Events, e)
+	}
+
+	return p
+}
+
+// After returns up to MaxLimit events with ID greater than id matching the filter, oldest first.
+// Before and Limit of the filter are ignored.
+func (s *Store) After(id uint64, f Filter) []Event {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	f.Before = 0
+
+	i, _ := slices.BinarySearchFunc(s.events, id+1, func(e Event, id uint64) int {
+		return cmp.Compare(e.ID, id)
+	})
+
+	var events []Event
+
+	for _, e := range s.events[i:] {
+		if len(events) == MaxLimit {
+			break
+		}
+
+		if f.Match(e) {
+			events = append(events, e)
+		}
+	}
+
+	return events
+}
+
+// Match returns true if the event matches the filter.
+func (f Filter) Match(e Event) bool {
+	switch {
+	case f.Before != 0 && e.ID >= f.Before:
+		return false
+	case f.Device != "" && e.Device != f.Device:
+		return false
+	case f.Type != "" && e.Type != f.Type:
+		return false
+	case !f.Since.IsZero() && e.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !e.Time.Before(f.Until):
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,237 @@
+package event
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const pruneInterval = time.Hour
+
+// Store is an append-only event store.
+// Events are kept in memory and, if path is set, appended to a JSON lines file that is replayed on start.
+// Events older than retention or exceeding maxEvents are pruned periodically, zero values disable the respective limit.
+type Store struct {
+	mux    *sync.RWMutex
+	events []Event
+	nextID uint64
+
+	path      string
+	file      *os.File
+	retention time.Duration
+	maxEvents int
+
+	close chan struct{}
+	wg    *sync.WaitGroup
+
+	l *slog.Logger
+}
+
+// Open loads events from the file at path and starts pruning. Empty path keeps events in memory only.
+func Open(path string, retention time.Duration, maxEvents int, l *slog.Logger) (*Store, error) {
+	s := &Store{
+		mux:       &sync.RWMutex{},
+		nextID:    1,
+		path:      path,
+		retention: retention,
+		maxEvents: maxEvents,
+		close:     make(chan struct{}),
+		wg:        &sync.WaitGroup{},
+		l:         l,
+	}
+
+	if path != "" {
+		if err := s.load(); err != nil {
+			return nil, fmt.Errorf("event store: %w", err)
+		}
+
+		if err := s.Prune(); err != nil {
+			return nil, fmt.Errorf("event store: %w", err)
+		}
+	}
+
+	s.wg.Add(1)
+
+	go s.pruneLoop()
+
+	return s, nil
+}
+
+// Record assigns an ID to the event, appends it to the store and returns the stored event.
+func (s *Store) Record(e Event) Event {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	e.ID = s.nextID
+	s.nextID++
+
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	s.events = append(s.events, e)
+
+	if s.file == nil {
+		return e
+	}
+
+	if err := json.NewEncoder(s.file).Encode(e); err != nil {
+		s.l.Error("failed to persist event", "id", e.ID, "err", err)
+	}
+
+	return e
+}
+
+// Prune drops events older than retention and the oldest events above maxEvents.
+func (s *Store) Prune() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	cutoff := time.Now().Add(-s.retention)
+	drop := 0
+
+	expired := func(e Event) bool { return s.retention > 0 && e.Time.Before(cutoff) }
+	excess := func(n int) bool { return s.maxEvents > 0 && n > s.maxEvents }
+
+	for drop < len(s.events) && (expired(s.events[drop]) || excess(len(s.events)-drop)) {
+		drop++
+	}
+
+	if drop > 0 {
+		s.events = append([]Event(nil), s.events[drop:]...)
+	}
+
+	// file is nil on start, so the first prune also opens it
+	if s.path == "" || (drop == 0 && s.file != nil) {
+		return nil
+	}
+
+	return s.rewrite()
+}
+
+// Close stops pruning and closes the underlying file.
+func (s *Store) Close() error {
+	close(s.close)
+	s.wg.Wait()
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("event store: close: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) pruneLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.close:
+			return
+		case <-ticker.C:
+			if err := s.Prune(); err != nil {
+				s.l.Error("failed to prune events", "err", err)
+			}
+		}
+	}
+}
+
+func (s *Store) load() error {
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("open: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// a partially written line is expected if hsd crashed mid-write
+			s.l.Warn("skipping malformed event", "err", err)
+			continue
+		}
+
+		s.events = append(s.events, e)
+		s.nextID = max(s.nextID, e.ID+1)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+
+	return nil
+}
+
+// rewrite atomically replaces the file with in-memory events and reopens it for appending.
+// Must be called with s.mux held.
+func (s *Store) rewrite() error {
+	if s.file != nil {
+		if err := s.file.Close(); err != nil {
+			s.l.Warn("failed to close event file", "err", err)
+		}
+
+		s.file = nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("rewrite: %w", err)
+	}
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+
+	for _, e := range s.events {
+		if err := enc.Encode(e); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+
+			return fmt.Errorf("rewrite: %w", err)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+
+		return fmt.Errorf("rewrite: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("rewrite: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("rewrite: %w", err)
+	}
+
+	s.file, err = os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("rewrite: reopen: %w", err)
+	}
+
+	return nil
+}
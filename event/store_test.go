@@ -0,0 +1,110 @@
+package event_test
+
+import (
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SuddenGunter/hsd/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestStore_PersistsEvents(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	s, err := event.Open(path, time.Hour, 100, discard())
+	require.NoError(t, err)
+
+	s.Record(event.Event{Type: event.TypeAlarm, Device: "door1", Message: "opened"})
+	s.Record(event.Event{Type: event.TypeStateChange, Source: "api", State: "disarmed"})
+	require.NoError(t, s.Close())
+
+	s, err = event.Open(path, time.Hour, 100, discard())
+	require.NoError(t, err)
+
+	defer s.Close()
+
+	s.Record(event.Event{Type: event.TypeAlarm, Device: "door2", Message: "opened"})
+
+	p := s.Query(event.Filter{})
+	require.Len(t, p.Events, 3)
+	assert.Equal(t, uint64(3), p.Events[0].ID)
+	assert.Equal(t, "door2", p.Events[0].Device)
+	assert.Equal(t, "door1", p.Events[2].Device)
+}
+
+func TestStore_PrunesExpiredEvents(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	s, err := event.Open(path, time.Hour, 2, discard())
+	require.NoError(t, err)
+
+	s.Record(event.Event{Type: event.TypeAlarm, Device: "old", Time: time.Now().Add(-2 * time.Hour)})
+	s.Record(event.Event{Type: event.TypeAlarm, Device: "door1"})
+	s.Record(event.Event{Type: event.TypeAlarm, Device: "door2"})
+	s.Record(event.Event{Type: event.TypeAlarm, Device: "door3"})
+	require.NoError(t, s.Prune())
+	require.NoError(t, s.Close())
+
+	s, err = event.Open(path, time.Hour, 2, discard())
+	require.NoError(t, err)
+
+	defer s.Close()
+
+	p := s.Query(event.Filter{})
+	require.Len(t, p.Events, 2)
+	assert.Equal(t, "door3", p.Events[0].Device)
+	assert.Equal(t, "door2", p.Events[1].Device)
+}
+
+func TestStore_ZeroLimitsKeepEvents(t *testing.T) {
+	t.Parallel()
+
+	s, err := event.Open("", 0, 0, discard())
+	require.NoError(t, err)
+
+	defer s.Close()
+
+	s.Record(event.Event{Type: event.TypeAlarm, Device: "old", Time: time.Now().Add(-24 * 365 * time.Hour)})
+	s.Record(event.Event{Type: event.TypeAlarm, Device: "door1"})
+	require.NoError(t, s.Prune())
+
+	assert.Len(t, s.Query(event.Filter{}).Events, 2)
+}
+
+func TestStore_QueryFiltersAndPaginates(t *testing.T) {
+	t.Parallel()
+
+	s, err := event.Open("", time.Hour, 100, discard())
+	require.NoError(t, err)
+
+	defer s.Close()
+
+	for range 3 {
+		s.Record(event.Event{Type: event.TypeDeviceUpdate, Device: "door1"})
+		s.Record(event.Event{Type: event.TypeAvailability, Device: "door1"})
+		s.Record(event.Event{Type: event.TypeDeviceUpdate, Device: "door2"})
+	}
+
+	p := s.Query(event.Filter{Device: "door1", Type: event.TypeDeviceUpdate, Limit: 2})
+	require.Len(t, p.Events, 2)
+	assert.Equal(t, uint64(7), p.Events[0].ID)
+	assert.Equal(t, uint64(4), p.Events[1].ID)
+	assert.Equal(t, uint64(4), p.Next)
+
+	p = s.Query(event.Filter{Device: "door1", Type: event.TypeDeviceUpdate, Limit: 2, Before: p.Next})
+	require.Len(t, p.Events, 1)
+	assert.Equal(t, uint64(1), p.Events[0].ID)
+	assert.Zero(t, p.Next)
+}
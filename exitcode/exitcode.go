@@ -1,6 +1,14 @@
 package exitcode
 
 const (
-	// LoadConfig is the OS exit code when the config fails to load.
+	// LoadConfig is the OS exit code when the config fails to load, e.g. the config file can't be read.
 	LoadConfig = 1
+	// Usage is the OS exit code when the command line is invalid.
+	Usage = 2
+	// InvalidConfig is the OS exit code when the config can't be parsed or fails validation.
+	InvalidConfig = 3
+	// Notify is the OS exit code when a test notification can't be delivered.
+	Notify = 4
+	// Request is the OS exit code when a ctl request to the hsd API fails.
+	Request = 5
 )
@@ -0,0 +1,98 @@
+package hass
+
+import "strings"
+
+// deviceState is published to the device topic on every device state update.
+type deviceState struct {
+	Available bool `json:"available"`
+	Opened    bool `json:"opened"`
+}
+
+// discoveryDevice groups all hsd entities under a single device in Home Assistant.
+type discoveryDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+}
+
+type availability struct {
+	Topic         string `json:"topic"`
+	ValueTemplate string `json:"value_template,omitempty"`
+}
+
+// https://www.home-assistant.io/integrations/alarm_control_panel.mqtt/
+type alarmPanelConfig struct {
+	Name                string          `json:"name"`
+	UniqueID            string          `json:"unique_id"`
+	StateTopic          string          `json:"state_topic"`
+	CommandTopic        string          `json:"command_topic"`
+	Availability        []availability  `json:"availability"`
+	SupportedFeatures   []string        `json:"supported_features"`
+	CodeArmRequired     bool            `json:"code_arm_required"`
+	CodeDisarmRequired  bool            `json:"code_disarm_required"`
+	CodeTriggerRequired bool            `json:"code_trigger_required"`
+	Device              discoveryDevice `json:"device"`
+}
+
+// https://www.home-assistant.io/integrations/binary_sensor.mqtt/
+type binarySensorConfig struct {
+	Name             string          `json:"name"`
+	UniqueID         string          `json:"unique_id"`
+	StateTopic       string          `json:"state_topic"`
+	ValueTemplate    string          `json:"value_template"`
+	DeviceClass      string          `json:"device_class"`
+	Availability     []availability  `json:"availability"`
+	AvailabilityMode string          `json:"availability_mode"`
+	Device           discoveryDevice `json:"device"`
+}
+
+func (b *Bridge) discoveryDevice() discoveryDevice {
+	return discoveryDevice{
+		Identifiers:  []string{b.topicPrefix},
+		Name:         "hsd",
+		Manufacturer: "hsd",
+		Model:        "Home security daemon",
+	}
+}
+
+func (b *Bridge) alarmPanelConfig() alarmPanelConfig {
+	return alarmPanelConfig{
+		Name:              "Alarm",
+		UniqueID:          b.topicPrefix + "_alarm",
+		StateTopic:        b.stateTopic(),
+		CommandTopic:      b.commandTopic(),
+		Availability:      []availability{{Topic: StatusTopic(b.topicPrefix)}},
+		SupportedFeatures: []string{"arm_home", "arm_away", "arm_night", "trigger"},
+		Device:            b.discoveryDevice(),
+	}
+}
+
+func (b *Bridge) binarySensorConfig(device string) binarySensorConfig {
+	return binarySensorConfig{
+		Name:          device,
+		UniqueID:      b.topicPrefix + "_" + objectID(device),
+		StateTopic:    b.deviceTopic(device),
+		ValueTemplate: "{{ 'ON' if value_json.opened else 'OFF' }}",
+		DeviceClass:   "door",
+		Availability: []availability{
+			{Topic: StatusTopic(b.topicPrefix)},
+			{Topic: b.deviceTopic(device), ValueTemplate: "{{ 'online' if value_json.available else 'offline' }}"},
+		},
+		AvailabilityMode: "all",
+		Device:           b.discoveryDevice(),
+	}
+}
+
+// objectID returns the device name with characters Home Assistant does not accept in object and unique IDs
+// replaced with underscores.
+func objectID(device string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, device)
+}
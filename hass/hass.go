@@ -0,0 +1,206 @@
+package hass
+
+import (
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Alarm control panel commands as defined by Home Assistant.
+// States are published as is, alarm.State values match Home Assistant states.
+// https://www.home-assistant.io/integrations/alarm_control_panel.mqtt/
+const (
+	cmdArmAway  = "ARM_AWAY"
+	cmdArmHome  = "ARM_HOME"
+	cmdArmNight = "ARM_NIGHT"
+	cmdDisarm   = "DISARM"
+	cmdTrigger  = "TRIGGER"
+
+	payloadOnline  = "online"
+	payloadOffline = "offline"
+)
+
+// StatusTopic returns the topic used to announce hsd availability to Home Assistant.
+func StatusTopic(topicPrefix string) string {
+	return topicPrefix + "/status"
+}
+
+// Bridge exposes the alarm and monitored devices to Home Assistant using MQTT discovery,
+// publishes their state and accepts arm/disarm commands from Home Assistant.
+type Bridge struct {
+	client  mqtt.Client
+	alarmer *alarm.Alarmer
+
+	mux     *sync.Mutex
+	devices []string
+
+	discoveryPrefix string
+	topicPrefix     string
+
+	l *slog.Logger
+}
+
+// NewBridge returns a new Bridge.
+func NewBridge(client mqtt.Client, alarmer *alarm.Alarmer, devices []string, discoveryPrefix, topicPrefix string, l *slog.Logger) *Bridge {
+	return &Bridge{
+		client:          client,
+		alarmer:         alarmer,
+		mux:             &sync.Mutex{},
+		devices:         slices.Clone(devices),
+		discoveryPrefix: discoveryPrefix,
+		topicPrefix:     topicPrefix,
+		l:               l,
+	}
+}
+
+// Start announces hsd to Home Assistant and subscribes to its commands.
+func (b *Bridge) Start() {
+	b.announce()
+
+	b.client.Subscribe(b.commandTopic(), 1, b.onCommand).Wait()
+	// Home Assistant forgets non-retained entities on restart, so discovery is repeated when it comes back online
+	b.client.Subscribe(b.discoveryPrefix+"/status", 1, b.onHomeAssistantStatus).Wait()
+}
+
+// Close marks hsd as unavailable in Home Assistant.
+func (b *Bridge) Close() {
+	b.client.Publish(StatusTopic(b.topicPrefix), 1, true, payloadOffline).Wait()
+}
+
+// AlarmStateChanged publishes the current alarm state.
+func (b *Bridge) AlarmStateChanged() {
+	b.publish(b.stateTopic(), []byte(b.alarmer.State()))
+}
+
+// DeviceStateChanged publishes the current state of the device.
+func (b *Bridge) DeviceStateChanged(device string, available, opened bool) {
+	payload, err := json.Marshal(deviceState{Available: available, Opened: opened})
+	if err != nil {
+		b.l.Error("failed to marshal device state", "device", device, "err", err)
+		return
+	}
+
+	b.publish(b.deviceTopic(device), payload)
+}
+
+// AddDevice announces a new device to Home Assistant.
+func (b *Bridge) AddDevice(device string) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if slices.Contains(b.devices, device) {
+		return
+	}
+
+	b.devices = append(b.devices, device)
+	b.publishDiscovery(b.sensorDiscoveryTopic(device), b.binarySensorConfig(device))
+}
+
+// RemoveDevice removes the device from Home Assistant.
+func (b *Bridge) RemoveDevice(device string) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.devices = slices.DeleteFunc(b.devices, func(d string) bool { return d == device })
+	// empty retained config removes the entity
+	b.publish(b.sensorDiscoveryTopic(device), nil)
+	b.publish(b.deviceTopic(device), nil)
+}
+
+func (b *Bridge) announce() {
+	b.publishDiscovery(b.discoveryPrefix+"/alarm_control_panel/hsd/alarm/config", b.alarmPanelConfig())
+
+	b.mux.Lock()
+	for _, d := range b.devices {
+		b.publishDiscovery(b.sensorDiscoveryTopic(d), b.binarySensorConfig(d))
+	}
+	b.mux.Unlock()
+
+	b.publish(StatusTopic(b.topicPrefix), []byte(payloadOnline))
+	b.AlarmStateChanged()
+}
+
+func (b *Bridge) onCommand(_ mqtt.Client, msg mqtt.Message) {
+	defer msg.Ack()
+
+	cmd := string(msg.Payload())
+	b.l.Info("home assistant command received", "command", cmd)
+
+	var err error
+
+	actor := alarm.Actor{Source: alarm.SourceHomeAssistant}
+
+	switch cmd {
+	case cmdArmAway:
+		err = b.alarmer.Arm(alarm.ModeAway, actor)
+	case cmdArmHome:
+		err = b.alarmer.Arm(alarm.ModeHome, actor)
+	case cmdArmNight:
+		err = b.alarmer.Arm(alarm.ModeNight, actor)
+	case cmdDisarm:
+		err = b.alarmer.Disarm(actor)
+	case cmdTrigger:
+		b.alarmer.Trigger(actor)
+	default:
+		b.l.Error("unsupported home assistant command", "command", cmd)
+		b.AlarmStateChanged()
+	}
+
+	if err != nil {
+		b.l.Warn("home assistant command rejected", "command", cmd, "err", err)
+		// let home assistant know the state did not change
+		b.AlarmStateChanged()
+	}
+}
+
+func (b *Bridge) onHomeAssistantStatus(_ mqtt.Client, msg mqtt.Message) {
+	defer msg.Ack()
+
+	if string(msg.Payload()) == payloadOnline {
+		b.l.Debug("home assistant is online, repeating discovery")
+		b.announce()
+	}
+}
+
+func (b *Bridge) publishDiscovery(topic string, cfg any) {
+	payload, err := json.Marshal(cfg)
+	if err != nil {
+		b.l.Error("failed to marshal discovery config", "topic", topic, "err", err)
+		return
+	}
+
+	b.publish(topic, payload)
+}
+
+// publish sends a retained message without waiting for delivery, so it is safe to call from mqtt callbacks.
+func (b *Bridge) publish(topic string, payload []byte) {
+	token := b.client.Publish(topic, 1, true, payload)
+
+	go func() {
+		<-token.Done()
+
+		if err := token.Error(); err != nil {
+			b.l.Error("failed to publish home assistant message", "topic", topic, "err", err)
+		}
+	}()
+}
+
+func (b *Bridge) stateTopic() string {
+	return b.topicPrefix + "/alarm/state"
+}
+
+func (b *Bridge) commandTopic() string {
+	return b.topicPrefix + "/alarm/set"
+}
+
+func (b *Bridge) sensorDiscoveryTopic(device string) string {
+	return b.discoveryPrefix + "/binary_sensor/hsd/" + objectID(device) + "/config"
+}
+
+func (b *Bridge) deviceTopic(device string) string {
+	return b.topicPrefix + "/device/" + device
+}
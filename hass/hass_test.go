@@ -0,0 +1,194 @@
+package hass_test
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	"github.com/SuddenGunter/hsd/audit"
+	"github.com/SuddenGunter/hsd/event"
+	"github.com/SuddenGunter/hsd/hass"
+	"github.com/SuddenGunter/hsd/notify/message"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// doneToken is a token of a completed operation.
+type doneToken struct{}
+
+func (doneToken) Wait() bool                     { return true }
+func (doneToken) WaitTimeout(time.Duration) bool { return true }
+func (doneToken) Error() error                   { return nil }
+
+func (doneToken) Done() <-chan struct{} {
+	done := make(chan struct{})
+	close(done)
+
+	return done
+}
+
+type fakeMessage struct {
+	mqtt.Message
+
+	topic   string
+	payload []byte
+}
+
+func (m fakeMessage) Topic() string   { return m.topic }
+func (m fakeMessage) Payload() []byte { return m.payload }
+func (m fakeMessage) Ack()            {}
+
+// fakeClient records retained messages and lets tests deliver messages to subscribers.
+type fakeClient struct {
+	mqtt.Client
+
+	mux      sync.Mutex
+	retained map[string]string
+	handlers map[string]mqtt.MessageHandler
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{retained: make(map[string]string), handlers: make(map[string]mqtt.MessageHandler)}
+}
+
+func (c *fakeClient) Publish(topic string, _ byte, _ bool, payload any) mqtt.Token {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	switch p := payload.(type) {
+	case string:
+		c.retained[topic] = p
+	case []byte:
+		c.retained[topic] = string(p)
+	}
+
+	return doneToken{}
+}
+
+func (c *fakeClient) Subscribe(topic string, _ byte, h mqtt.MessageHandler) mqtt.Token {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.handlers[topic] = h
+
+	return doneToken{}
+}
+
+func (c *fakeClient) deliver(topic, payload string) {
+	c.mux.Lock()
+	h := c.handlers[topic]
+	c.mux.Unlock()
+
+	h(c, fakeMessage{topic: topic, payload: []byte(payload)})
+}
+
+func (c *fakeClient) get(topic string) string {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return c.retained[topic]
+}
+
+type nopNotifier struct{}
+
+func (nopNotifier) Notify(message.Message) {}
+
+type nopRecorder struct{}
+
+func (nopRecorder) Record(event.Event) {}
+
+type nopAuditor struct{}
+
+func (nopAuditor) Audit(audit.Entry) {}
+
+func TestBridge_Commands(t *testing.T) {
+	t.Parallel()
+
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+	a := alarm.New(nopNotifier{}, nopRecorder{}, nopAuditor{}, alarm.Options{}, l)
+	c := newFakeClient()
+
+	b := hass.NewBridge(c, a, []string{"door1"}, "homeassistant", "hsd", l)
+	a.Observe(b)
+	b.Start()
+
+	assert.Equal(t, "online", c.get(hass.StatusTopic("hsd")))
+	assert.NotEmpty(t, c.get("homeassistant/alarm_control_panel/hsd/alarm/config"))
+	assert.NotEmpty(t, c.get("homeassistant/binary_sensor/hsd/door1/config"))
+	assert.Equal(t, string(alarm.StateArmedAway), c.get("hsd/alarm/state"))
+
+	tests := []struct {
+		command string
+		state   alarm.State
+	}{
+		{command: "DISARM", state: alarm.StateDisarmed},
+		{command: "ARM_HOME", state: alarm.StateArmedHome},
+		{command: "ARM_NIGHT", state: alarm.StateArmedNight},
+		{command: "ARM_AWAY", state: alarm.StateArmedAway},
+		{command: "TRIGGER", state: alarm.StateTriggered},
+		{command: "DISARM", state: alarm.StateDisarmed},
+		// rejected and unknown commands republish the unchanged state
+		{command: "DISARM", state: alarm.StateDisarmed},
+		{command: "ARM_VACATION", state: alarm.StateDisarmed},
+	}
+
+	for _, tt := range tests {
+		c.deliver("hsd/alarm/set", tt.command)
+
+		require.Equal(t, tt.state, a.State(), tt.command)
+		assert.Equal(t, string(tt.state), c.get("hsd/alarm/state"), tt.command)
+	}
+
+	b.Close()
+	assert.Equal(t, "offline", c.get(hass.StatusTopic("hsd")))
+}
+
+func TestBridge_Devices(t *testing.T) {
+	t.Parallel()
+
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+	a := alarm.New(nopNotifier{}, nopRecorder{}, nopAuditor{}, alarm.Options{}, l)
+	c := newFakeClient()
+
+	b := hass.NewBridge(c, a, nil, "homeassistant", "hsd", l)
+	b.Start()
+
+	b.AddDevice("door2")
+	b.DeviceStateChanged("door2", true, true)
+
+	assert.NotEmpty(t, c.get("homeassistant/binary_sensor/hsd/door2/config"))
+	assert.JSONEq(t, `{"available":true,"opened":true}`, c.get("hsd/device/door2"))
+
+	b.RemoveDevice("door2")
+
+	assert.Empty(t, c.get("homeassistant/binary_sensor/hsd/door2/config"))
+	assert.Empty(t, c.get("hsd/device/door2"))
+}
+
+func TestBridge_DeviceNameWithSpaces(t *testing.T) {
+	t.Parallel()
+
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+	a := alarm.New(nopNotifier{}, nopRecorder{}, nopAuditor{}, alarm.Options{}, l)
+	c := newFakeClient()
+
+	b := hass.NewBridge(c, a, []string{"front door"}, "homeassistant", "hsd", l)
+	b.Start()
+
+	var config struct {
+		Name     string `json:"name"`
+		UniqueID string `json:"unique_id"`
+	}
+
+	require.NoError(t, json.Unmarshal([]byte(c.get("homeassistant/binary_sensor/hsd/front_door/config")), &config))
+	assert.Equal(t, "front door", config.Name)
+	assert.Equal(t, "hsd_front_door", config.UniqueID)
+
+	b.RemoveDevice("front door")
+	assert.Empty(t, c.get("homeassistant/binary_sensor/hsd/front_door/config"))
+}
@@ -2,42 +2,139 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"github.com/SuddenGunter/hsd/app"
 	"github.com/SuddenGunter/hsd/app/config"
+	"github.com/SuddenGunter/hsd/ctl"
 	"github.com/SuddenGunter/hsd/exitcode"
 )
 
-var verbose = flag.Bool("v", false, "enable verbose logs")
+// command is a hsd subcommand. run returns the OS exit code.
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) int
+}
+
+func commands() []command {
+	return []command{
+		{name: "run", usage: "start the daemon (default)", run: runDaemon},
+		{name: "check-config", usage: "validate the config and print the effective config with secrets redacted", run: checkConfig},
+		{name: "test-notify", usage: "send a test message through every configured notifier", run: testNotify},
+		{name: "ctl", usage: "control a running hsd through its HTTP API, see hsd ctl -h", run: runCtl},
+		{name: "version", usage: "print the version", run: printVersion},
+	}
+}
 
 func main() {
-	flag.Parse()
+	args := os.Args[1:]
+
+	// flags without a command run the daemon, e.g. hsd -v
+	name := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	for _, cmd := range commands() {
+		if cmd.name == name {
+			os.Exit(cmd.run(args))
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	usage()
+	os.Exit(exitcode.Usage)
+}
 
-	l := slog.New(slog.NewTextHandler(os.Stdout, logOpts()))
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: hsd [command] [flags]\n\nCommands:\n")
+
+	for _, cmd := range commands() {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", cmd.name, cmd.usage)
+	}
+
+	fmt.Fprintf(os.Stderr, "\nRun hsd <command> -h for command flags.\n")
+}
+
+// options are flags shared by commands.
+type options struct {
+	verbose    bool
+	configFile string
+}
+
+// parseFlags parses common flags of the command. It exits on invalid flags.
+func parseFlags(name string, args []string) options {
+	var opts options
+
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.BoolVar(&opts.verbose, "v", false, "enable verbose logs")
+	fs.StringVar(&opts.configFile, "config", os.Getenv("CONFIG_FILE"), "path to the YAML config file, defaults to CONFIG_FILE")
+
+	// ExitOnError exits with code 2, which is exitcode.Usage
+	_ = fs.Parse(args)
+
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "%s: unexpected arguments: %s\n", name, strings.Join(fs.Args(), " "))
+		os.Exit(exitcode.Usage)
+	}
+
+	return opts
+}
+
+func (opts options) logger() *slog.Logger {
+	if opts.verbose {
+		return slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+
+	return slog.New(slog.NewTextHandler(os.Stdout, nil))
+}
+
+// loadConfig loads the config and returns the exit code matching the error.
+func (opts options) loadConfig() (*config.Config, int, error) {
+	cfg, err := config.Load(opts.configFile)
+	if errors.Is(err, config.ErrInvalidConfig) {
+		return nil, exitcode.InvalidConfig, err
+	}
 
-	cfg, err := config.LoadEnv()
+	if err != nil {
+		return nil, exitcode.LoadConfig, err
+	}
+
+	return cfg, 0, nil
+}
+
+func runDaemon(args []string) int {
+	opts := parseFlags("run", args)
+	l := opts.logger()
+
+	cfg, code, err := opts.loadConfig()
 	if err != nil {
 		l.Error("failed to load config", "err", err)
-		os.Exit(exitcode.LoadConfig)
+		return code
 	}
 
 	l.Debug("config loaded", "cfg", cfg)
+	l.Info("starting hsd", "version", version())
 
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
 	app.New(l, cfg).Run(ctx)
+
+	return 0
 }
 
-func logOpts() *slog.HandlerOptions {
-	if *verbose {
-		return &slog.HandlerOptions{Level: slog.LevelDebug}
-	}
+func runCtl(args []string) int {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	return nil
+	return ctl.Run(ctx, args, os.Stdout, os.Stderr)
 }
@@ -0,0 +1,192 @@
+// Package metrics implements a minimal set of Prometheus metric types and the text exposition format.
+// https://prometheus.io/docs/instrumenting/exposition_formats/#text-based-format
+package metrics
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default is the registry used by the package level constructors.
+var Default = NewRegistry()
+
+type collector interface {
+	describe() (name, help, typ string)
+	collect(ctx context.Context, w *bufio.Writer)
+}
+
+// Registry holds metrics and exposes them in Prometheus text format.
+type Registry struct {
+	mux        *sync.Mutex
+	collectors []collector
+	names      map[string]struct{}
+	hooks      []func(ctx context.Context)
+	includes   []*Registry
+}
+
+// NewRegistry returns an empty registry. Metrics of the included registries are written along with its own,
+// e.g. a registry of a single app run can include Default.
+func NewRegistry(include ...*Registry) *Registry {
+	return &Registry{mux: &sync.Mutex{}, names: make(map[string]struct{}), includes: include}
+}
+
+// BeforeWrite registers fn to be called before metrics are written, e.g. to take a snapshot several GaugeFuncs read.
+func (r *Registry) BeforeWrite(fn func(ctx context.Context)) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.hooks = append(r.hooks, fn)
+}
+
+// register panics on duplicate names, as it is a programming error.
+func (r *Registry) register(c collector) {
+	name, _, _ := c.describe()
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if _, ok := r.names[name]; ok {
+		panic(fmt.Sprintf("metrics: duplicate metric %q", name))
+	}
+
+	r.names[name] = struct{}{}
+	r.collectors = append(r.collectors, c)
+}
+
+// Write writes all metrics in Prometheus text format.
+func (r *Registry) Write(ctx context.Context, w io.Writer) error {
+	collectors, hooks := r.snapshot()
+
+	for _, fn := range hooks {
+		fn(ctx)
+	}
+
+	slices.SortFunc(collectors, func(a, b collector) int {
+		an, _, _ := a.describe()
+		bn, _, _ := b.describe()
+
+		return cmp.Compare(an, bn)
+	})
+
+	bw := bufio.NewWriter(w)
+
+	for _, c := range collectors {
+		name, help, typ := c.describe()
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, typ)
+		c.collect(ctx, bw)
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("metrics: write: %w", err)
+	}
+
+	return nil
+}
+
+// snapshot returns the collectors and hooks of the registry and the registries it includes.
+func (r *Registry) snapshot() ([]collector, []func(context.Context)) {
+	r.mux.Lock()
+	collectors, hooks := slices.Clone(r.collectors), slices.Clone(r.hooks)
+	r.mux.Unlock()
+
+	for _, inc := range r.includes {
+		c, h := inc.snapshot()
+		collectors, hooks = append(collectors, c...), append(hooks, h...)
+	}
+
+	return collectors, hooks
+}
+
+// Handler returns an http.Handler serving the metrics.
+func (r *Registry) Handler(l *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+		if err := r.Write(ctx, w); err != nil {
+			l.Warn("failed to write metrics", "err", err, "path", req.URL.Path)
+		}
+	})
+}
+
+// series is a set of label values identifying a single time series of a metric.
+type series struct {
+	key    string
+	values []string
+}
+
+func newSeries(labels, values []string) series {
+	if len(labels) != len(values) {
+		panic(fmt.Sprintf("metrics: expected %d label values, got %d", len(labels), len(values)))
+	}
+
+	return series{key: strings.Join(values, "\xff"), values: values}
+}
+
+// writeSample writes a single sample line, extra is an additional label (e.g. histogram "le") if not empty.
+func writeSample(w *bufio.Writer, name string, labels, values []string, extra, extraValue string, v float64) {
+	w.WriteString(name)
+
+	if len(labels) > 0 || extra != "" {
+		w.WriteByte('{')
+
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+
+			fmt.Fprintf(w, "%s=\"%s\"", l, escapeLabel(values[i]))
+		}
+
+		if extra != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+
+			fmt.Fprintf(w, "%s=\"%s\"", extra, extraValue)
+		}
+
+		w.WriteByte('}')
+	}
+
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
@@ -0,0 +1,103 @@
+package metrics_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/SuddenGunter/hsd/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_Write(t *testing.T) {
+	t.Parallel()
+
+	r := metrics.NewRegistry()
+
+	c := r.NewCounter("test_messages_total", "Messages received.", "device", "type")
+	c.Inc("door1", "data")
+	c.Inc("door1", "data")
+	c.Inc(`we"ird`, "availability")
+
+	h := r.NewHistogram("test_duration_seconds", "Duration.", []float64{1, 0.1})
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(2)
+
+	r.NewGaugeFunc("test_up", "Up.", func(context.Context) []metrics.Sample {
+		return []metrics.Sample{{Value: 1}}
+	})
+
+	var sb strings.Builder
+	require.NoError(t, r.Write(t.Context(), &sb))
+
+	assert.Equal(t, `# HELP test_duration_seconds Duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{le="0.1"} 1
+test_duration_seconds_bucket{le="1"} 2
+test_duration_seconds_bucket{le="+Inf"} 3
+test_duration_seconds_sum 2.55
+test_duration_seconds_count 3
+# HELP test_messages_total Messages received.
+# TYPE test_messages_total counter
+test_messages_total{device="door1",type="data"} 2
+test_messages_total{device="we\"ird",type="availability"} 1
+# HELP test_up Up.
+# TYPE test_up gauge
+test_up 1
+`, sb.String())
+}
+
+func TestRegistry_DuplicateNamePanics(t *testing.T) {
+	t.Parallel()
+
+	r := metrics.NewRegistry()
+	r.NewCounter("test_total", "Test.")
+
+	assert.Panics(t, func() { r.NewCounter("test_total", "Test.") })
+}
+
+func TestRegistry_IncludeAndBeforeWrite(t *testing.T) {
+	t.Parallel()
+
+	base := metrics.NewRegistry()
+	base.NewCounter("test_total", "Test.").Inc()
+
+	write := func() string {
+		t.Helper()
+
+		// a registry per run can register the same metrics again
+		r := metrics.NewRegistry(base)
+
+		snapshots, value := 0, 0.0
+		r.BeforeWrite(func(context.Context) {
+			snapshots++
+			value = float64(snapshots)
+		})
+
+		for _, name := range []string{"test_a", "test_b"} {
+			r.NewGaugeFunc(name, "Test.", func(context.Context) []metrics.Sample {
+				return []metrics.Sample{{Value: value}}
+			})
+		}
+
+		var sb strings.Builder
+		require.NoError(t, r.Write(t.Context(), &sb))
+
+		return sb.String()
+	}
+
+	want := `# HELP test_a Test.
+# TYPE test_a gauge
+test_a 1
+# HELP test_b Test.
+# TYPE test_b gauge
+test_b 1
+# HELP test_total Test.
+# TYPE test_total counter
+test_total 1
+`
+	assert.Equal(t, want, write())
+	assert.Equal(t, want, write())
+}
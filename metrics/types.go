@@ -0,0 +1,216 @@
+package metrics
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+)
+
+// DefaultBuckets are histogram buckets suitable for network request durations in seconds.
+var DefaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Counter is a monotonically increasing value partitioned by labels.
+type Counter struct {
+	name, help string
+	labels     []string
+
+	mux    *sync.Mutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	series
+	v float64
+}
+
+// NewCounter registers a new Counter in the Default registry.
+func NewCounter(name, help string, labels ...string) *Counter {
+	return Default.NewCounter(name, help, labels...)
+}
+
+// NewCounter registers a new Counter.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{name: name, help: help, labels: labels, mux: &sync.Mutex{}, series: make(map[string]*counterSeries)}
+	r.register(c)
+
+	return c
+}
+
+// Inc increments the counter for the label values.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds v to the counter for the label values.
+func (c *Counter) Add(v float64, values ...string) {
+	s := newSeries(c.labels, values)
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	cs, ok := c.series[s.key]
+	if !ok {
+		cs = &counterSeries{series: s}
+		c.series[s.key] = cs
+	}
+
+	cs.v += v
+}
+
+func (c *Counter) describe() (string, string, string) {
+	return c.name, c.help, "counter"
+}
+
+func (c *Counter) collect(_ context.Context, w *bufio.Writer) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	for _, s := range sortedSeries(c.series) {
+		writeSample(w, c.name, c.labels, s.values, "", "", s.v)
+	}
+}
+
+// Histogram counts observations in buckets partitioned by labels.
+type Histogram struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+
+	mux    *sync.Mutex
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	series
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram registers a new Histogram in the Default registry.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return Default.NewHistogram(name, help, buckets, labels...)
+}
+
+// NewHistogram registers a new Histogram. Buckets are upper bounds, +Inf is added implicitly.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	buckets = slices.Clone(buckets)
+	slices.Sort(buckets)
+
+	h := &Histogram{
+		name:    name,
+		help:    help,
+		labels:  labels,
+		buckets: buckets,
+		mux:     &sync.Mutex{},
+		series:  make(map[string]*histogramSeries),
+	}
+	r.register(h)
+
+	return h
+}
+
+// Observe adds an observation for the label values.
+func (h *Histogram) Observe(v float64, values ...string) {
+	s := newSeries(h.labels, values)
+
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	hs, ok := h.series[s.key]
+	if !ok {
+		hs = &histogramSeries{series: s, counts: make([]uint64, len(h.buckets))}
+		h.series[s.key] = hs
+	}
+
+	for i, b := range h.buckets {
+		if v <= b {
+			hs.counts[i]++
+		}
+	}
+
+	hs.count++
+	hs.sum += v
+}
+
+func (h *Histogram) describe() (string, string, string) {
+	return h.name, h.help, "histogram"
+}
+
+func (h *Histogram) collect(_ context.Context, w *bufio.Writer) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	for _, s := range sortedSeries(h.series) {
+		for i, b := range h.buckets {
+			writeSample(w, h.name+"_bucket", h.labels, s.values, "le", formatFloat(b), float64(s.counts[i]))
+		}
+
+		writeSample(w, h.name+"_bucket", h.labels, s.values, "le", "+Inf", float64(s.count))
+		writeSample(w, h.name+"_sum", h.labels, s.values, "", "", s.sum)
+		writeSample(w, h.name+"_count", h.labels, s.values, "", "", float64(s.count))
+	}
+}
+
+// Sample is a single gauge value returned by a GaugeFunc.
+type Sample struct {
+	Values []string
+	Value  float64
+}
+
+// GaugeFunc is a gauge whose values are computed on every scrape.
+type GaugeFunc struct {
+	name, help string
+	labels     []string
+	fn         func(ctx context.Context) []Sample
+}
+
+// NewGaugeFunc registers a new GaugeFunc in the Default registry.
+func NewGaugeFunc(name, help string, fn func(ctx context.Context) []Sample, labels ...string) *GaugeFunc {
+	return Default.NewGaugeFunc(name, help, fn, labels...)
+}
+
+// NewGaugeFunc registers a new GaugeFunc. fn must return label values in the order of labels.
+func (r *Registry) NewGaugeFunc(name, help string, fn func(ctx context.Context) []Sample, labels ...string) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, labels: labels, fn: fn}
+	r.register(g)
+
+	return g
+}
+
+func (g *GaugeFunc) describe() (string, string, string) {
+	return g.name, g.help, "gauge"
+}
+
+func (g *GaugeFunc) collect(ctx context.Context, w *bufio.Writer) {
+	for _, s := range g.fn(ctx) {
+		if len(s.Values) != len(g.labels) {
+			continue
+		}
+
+		writeSample(w, g.name, g.labels, s.Values, "", "", s.Value)
+	}
+}
+
+type keyed interface {
+	seriesKey() string
+}
+
+func (s series) seriesKey() string {
+	return s.key
+}
+
+func sortedSeries[T keyed](m map[string]T) []T {
+	res := make([]T, 0, len(m))
+	for _, s := range m {
+		res = append(res, s)
+	}
+
+	slices.SortFunc(res, func(a, b T) int {
+		return cmp.Compare(a.seriesKey(), b.seriesKey())
+	})
+
+	return res
+}
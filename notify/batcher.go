@@ -0,0 +1,146 @@
+package notify
+
+import (
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	"github.com/SuddenGunter/hsd/notify/message"
+)
+
+// maxBatchItems limits the size of a summary, telegram rejects messages over 4096 characters.
+const maxBatchItems = 30
+
+// summaryDevice is the device name summaries are sent for.
+const summaryDevice = "hsd"
+
+// BatcherOptions configure the Batcher.
+type BatcherOptions struct {
+	// Window is how long messages following a delivered one are held back and coalesced. Zero disables batching.
+	Window time.Duration
+	// Clock schedules the end of windows, alarm.SystemClock if nil.
+	Clock alarm.Clock
+}
+
+// Batcher coalesces bursts of notifications, e.g. when many sensors change at once after a power blip.
+// Critical messages are always delivered right away. The first of the other messages is delivered right away
+// too, and the ones arriving within the window after it are delivered as one summary when the window ends.
+type Batcher struct {
+	next   Sender
+	window time.Duration
+	clock  alarm.Clock
+	l      *slog.Logger
+
+	mux     *sync.Mutex
+	timer   alarm.Timer
+	pending []message.Message
+}
+
+// NewBatcher returns a new Batcher delivering to next.
+func NewBatcher(next Sender, opts BatcherOptions, l *slog.Logger) *Batcher {
+	clock := opts.Clock
+	if clock == nil {
+		clock = alarm.SystemClock{}
+	}
+
+	return &Batcher{next: next, window: opts.Window, clock: clock, l: l, mux: &sync.Mutex{}}
+}
+
+// Notify delivers the message, or holds it back until the current window ends unless it is critical.
+// Delivery errors are logged.
+func (b *Batcher) Notify(m message.Message) {
+	if b.window <= 0 || m.Severity == message.Critical {
+		b.send(m)
+		return
+	}
+
+	b.mux.Lock()
+
+	if b.timer != nil {
+		b.pending = append(b.pending, m)
+		b.mux.Unlock()
+
+		return
+	}
+
+	b.timer = b.clock.AfterFunc(b.window, b.endWindow)
+	b.mux.Unlock()
+
+	b.send(m)
+}
+
+// Close delivers held back messages right away.
+func (b *Batcher) Close() {
+	b.mux.Lock()
+
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+
+	pending := b.pending
+	b.pending = nil
+	b.mux.Unlock()
+
+	b.sendBatch(pending)
+}
+
+// endWindow delivers held back messages. The next window starts right away if there were any,
+// so a long burst is delivered as one summary per window.
+func (b *Batcher) endWindow() {
+	b.mux.Lock()
+
+	pending := b.pending
+	b.pending = nil
+
+	if len(pending) > 0 {
+		b.timer = b.clock.AfterFunc(b.window, b.endWindow)
+	} else {
+		b.timer = nil
+	}
+
+	b.mux.Unlock()
+
+	b.sendBatch(pending)
+}
+
+func (b *Batcher) sendBatch(batch []message.Message) {
+	switch len(batch) {
+	case 0:
+		return
+	case 1:
+		b.send(batch[0])
+		return
+	}
+
+	b.l.Info("notifications batched", "count", len(batch))
+	b.send(summarize(batch))
+}
+
+func (b *Batcher) send(m message.Message) {
+	if err := b.next.Send(m); err != nil {
+		b.l.Error("notification delivery failed", "device", m.Device, "err", err)
+	}
+}
+
+// summarize returns a summary of the batch, as urgent as its most urgent message.
+func summarize(batch []message.Message) message.Message {
+	m := message.New(message.KeyBatch, message.Info, summaryDevice, "count", strconv.Itoa(len(batch)))
+
+	for _, item := range batch {
+		if moreUrgent(item.Severity, m.Severity) {
+			m.Severity = item.Severity
+		}
+	}
+
+	if len(batch) > maxBatchItems {
+		m.Args["more"] = strconv.Itoa(len(batch) - maxBatchItems)
+		batch = batch[:maxBatchItems]
+	}
+
+	m.Items = batch
+
+	return m
+}
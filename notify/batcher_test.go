@@ -0,0 +1,144 @@
+package notify_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SuddenGunter/hsd/alarm/alarmtest"
+	"github.com/SuddenGunter/hsd/notify"
+	"github.com/SuddenGunter/hsd/notify/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+type fakeSender struct {
+	mux  sync.Mutex
+	sent []message.Message
+	err  error
+}
+
+func (s *fakeSender) Send(m message.Message) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.sent = append(s.sent, m)
+
+	return s.err
+}
+
+// messages returns English texts of sent messages, one line per summary item.
+func (s *fakeSender) messages() []string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	texts := make([]string, 0, len(s.sent))
+
+	for _, m := range s.sent {
+		text := m.Device + ": " + m.String()
+		if m.Delayed {
+			text += " (delayed, from " + m.Time.Format(time.DateTime) + ")"
+		}
+
+		for _, item := range m.Items {
+			text += "\n" + item.Device + ": " + item.String()
+		}
+
+		texts = append(texts, text)
+	}
+
+	return texts
+}
+
+func alarmMessage(device, reason string) message.Message {
+	return message.New(message.KeyAlarm, message.Critical, device, "reason", reason)
+}
+
+func noticeMessage(device string) message.Message {
+	return message.New(message.KeyBypassExpired, message.Warning, device)
+}
+
+func TestBatcher_CoalescesBurst(t *testing.T) {
+	t.Parallel()
+
+	s, c := &fakeSender{}, &alarmtest.Clock{}
+	b := notify.NewBatcher(s, notify.BatcherOptions{Window: 2 * time.Second, Clock: c}, discard)
+
+	b.Notify(noticeMessage("door1"))
+	assert.Equal(t, []string{"door1: bypass expired, monitoring resumed"}, s.messages(), "the first message is not delayed")
+
+	b.Notify(noticeMessage("door2"))
+	b.Notify(noticeMessage("door3"))
+	assert.Len(t, s.messages(), 1)
+
+	c.Advance(2 * time.Second)
+	assert.Equal(t, []string{
+		"door1: bypass expired, monitoring resumed",
+		"hsd: 2 notifications:\ndoor2: bypass expired, monitoring resumed\ndoor3: bypass expired, monitoring resumed",
+	}, s.messages())
+
+	// the burst continues in the next window
+	b.Notify(noticeMessage("door1"))
+	assert.Len(t, s.messages(), 2)
+
+	c.Advance(2 * time.Second)
+	assert.Equal(t, "door1: bypass expired, monitoring resumed", s.messages()[2], "a single message is delivered as is")
+
+	// the window closed without messages, so the next one is delivered right away
+	c.Advance(2 * time.Second)
+	b.Notify(message.New(message.KeyDisarmed, message.Info, "alarm", "actor", "alice via api"))
+	assert.Equal(t, "alarm: disarmed by alice via api", s.messages()[3])
+}
+
+func TestBatcher_CriticalNotHeldBack(t *testing.T) {
+	t.Parallel()
+
+	s, c := &fakeSender{}, &alarmtest.Clock{}
+	b := notify.NewBatcher(s, notify.BatcherOptions{Window: 2 * time.Second, Clock: c}, discard)
+
+	b.Notify(message.New(message.KeyDisarmed, message.Info, "alarm", "actor", "alice via api"))
+	b.Notify(noticeMessage("window1"))
+	b.Notify(alarmMessage("door1", "opened"))
+	b.Notify(alarmMessage("door2", "opened"))
+	assert.Equal(t, []string{"alarm: disarmed by alice via api", "door1: opened", "door2: opened"}, s.messages(),
+		"critical messages arriving within the window are delivered right away")
+
+	c.Advance(2 * time.Second)
+	assert.Equal(t, "window1: bypass expired, monitoring resumed", s.messages()[3])
+}
+
+func TestBatcher_CloseFlushes(t *testing.T) {
+	t.Parallel()
+
+	s := &fakeSender{err: errors.New("telegram is down")}
+	b := notify.NewBatcher(s, notify.BatcherOptions{Window: time.Minute, Clock: &alarmtest.Clock{}}, discard)
+
+	for i := range 40 {
+		b.Notify(noticeMessage(fmt.Sprintf("door%d", i)))
+	}
+
+	b.Close()
+
+	msgs := s.messages()
+	require.Len(t, msgs, 2)
+	assert.True(t, strings.HasPrefix(msgs[1], "hsd: 39 notifications, 9 not listed:\ndoor1: bypass expired, monitoring resumed\n"))
+	assert.Equal(t, 31, strings.Count(msgs[1], "\n")+1, "the summary lists 30 notifications")
+}
+
+func TestBatcher_Disabled(t *testing.T) {
+	t.Parallel()
+
+	s := &fakeSender{}
+	b := notify.NewBatcher(s, notify.BatcherOptions{}, discard)
+
+	b.Notify(alarmMessage("door1", "opened"))
+	b.Notify(alarmMessage("door2", "opened"))
+	assert.Equal(t, []string{"door1: opened", "door2: opened"}, s.messages())
+}
@@ -0,0 +1,203 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	"github.com/SuddenGunter/hsd/event"
+	"github.com/SuddenGunter/hsd/notify/message"
+)
+
+// digestDevice is the device name digests are sent for.
+const digestDevice = "digest"
+
+// digestPeriod is the period a digest covers.
+const digestPeriod = 24 * time.Hour
+
+// maxDigestTransitions limits state changes listed in a digest, the newest are kept.
+const maxDigestTransitions = 20
+
+type eventQuerier interface {
+	Query(f event.Filter) event.Page
+}
+
+type deviceStatuses interface {
+	Statuses(ctx context.Context) ([]alarm.DeviceStatus, error)
+}
+
+// DigestOptions configure the Digest.
+type DigestOptions struct {
+	// At is the time of day the digest is sent, as an offset from midnight in local time.
+	At time.Duration
+	// LowBattery is the battery percentage at or below which devices are listed.
+	LowBattery int
+}
+
+// Digest sends a daily summary of non-urgent events: alarms, arm and disarm history,
+// devices going offline and low batteries.
+type Digest struct {
+	sender  Sender
+	events  eventQuerier
+	devices deviceStatuses
+	opts    DigestOptions
+	l       *slog.Logger
+}
+
+// NewDigest returns a new Digest.
+func NewDigest(sender Sender, events eventQuerier, devices deviceStatuses, opts DigestOptions, l *slog.Logger) *Digest {
+	return &Digest{sender: sender, events: events, devices: devices, opts: opts, l: l}
+}
+
+// Run sends the digest every day until ctx is done.
+func (d *Digest) Run(ctx context.Context) {
+	for {
+		next := d.Next(time.Now())
+		d.l.Debug("next digest scheduled", "at", next)
+
+		t := time.NewTimer(time.Until(next))
+
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case now := <-t.C:
+			if err := d.Send(ctx, now); err != nil {
+				d.l.Error("failed to send digest", "err", err)
+			}
+		}
+	}
+}
+
+// Send sends the digest of the day before now.
+func (d *Digest) Send(ctx context.Context, now time.Time) error {
+	msg, err := d.Build(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	if err := d.sender.Send(msg); err != nil {
+		return fmt.Errorf("digest: %w", err)
+	}
+
+	return nil
+}
+
+// Build returns the digest of the day before now. Its items list state changes, devices that went offline
+// and devices with low battery.
+func (d *Digest) Build(ctx context.Context, now time.Time) (message.Message, error) {
+	statuses, err := d.devices.Statuses(ctx)
+	if err != nil {
+		return message.Message{}, fmt.Errorf("digest: %w", err)
+	}
+
+	period := event.Filter{Since: now.Add(-digestPeriod), Until: now}
+
+	var (
+		alarms      int
+		transitions []message.Message
+		offline     = make(map[string]int)
+	)
+
+	d.each(period, event.TypeAlarm, func(event.Event) bool {
+		alarms++
+		return true
+	})
+
+	// events are newest first
+	d.each(period, event.TypeStateChange, func(e event.Event) bool {
+		actor := alarm.Actor{Name: e.Actor, Source: e.Source}
+		transitions = append(transitions, message.New(message.KeyDigestState, message.Info, "alarm",
+			"time", e.Time.Local().Format("15:04"), "state", e.State, "actor", actor.String()))
+
+		return len(transitions) < maxDigestTransitions
+	})
+
+	d.each(period, event.TypeAvailability, func(e event.Event) bool {
+		if e.Available != nil && !*e.Available {
+			offline[e.Device]++
+		}
+
+		return true
+	})
+
+	slices.Reverse(transitions)
+	items := transitions
+
+	for _, device := range slices.Sorted(maps.Keys(offline)) {
+		items = append(items, message.New(message.KeyDigestOffline, message.Info, device, "count", strconv.Itoa(offline[device])))
+	}
+
+	items = append(items, d.lowBattery(statuses)...)
+
+	m := message.New(message.KeyDigest, message.Info, digestDevice)
+	m.Items = items
+
+	switch {
+	case alarms > 0:
+		m.Args = map[string]string{"alarms": strconv.Itoa(alarms)}
+	case len(items) == 0:
+		m.Args = map[string]string{"empty": "true"}
+	}
+
+	return m, nil
+}
+
+// each calls fn for events of the type in the period, newest first, following pages until fn returns false.
+func (d *Digest) each(period event.Filter, typ event.Type, fn func(event.Event) bool) {
+	f := period
+	f.Type = typ
+
+	for {
+		page := d.events.Query(f)
+
+		for _, e := range page.Events {
+			if !fn(e) {
+				return
+			}
+		}
+
+		if page.Next == 0 {
+			return
+		}
+
+		f.Before = page.Next
+	}
+}
+
+func (d *Digest) lowBattery(statuses []alarm.DeviceStatus) []message.Message {
+	var low []message.Message
+
+	for _, s := range statuses {
+		// battery is unknown until the device reports
+		if s.LastUpdated == nil || s.Battery > d.opts.LowBattery {
+			continue
+		}
+
+		low = append(low, message.New(message.KeyDigestBattery, message.Info, s.Name, "battery", strconv.Itoa(s.Battery)))
+	}
+
+	slices.SortFunc(low, func(a, b message.Message) int { return strings.Compare(a.Device, b.Device) })
+
+	return low
+}
+
+// Next returns the first time after now the digest is sent. The time of day is kept on days
+// daylight saving time starts or ends.
+func (d *Digest) Next(now time.Time) time.Time {
+	at := d.opts.At
+	h, m, sec := int(at/time.Hour), int(at%time.Hour/time.Minute), int(at%time.Minute/time.Second)
+
+	next := time.Date(now.Year(), now.Month(), now.Day(), h, m, sec, 0, now.Location())
+	if !next.After(now) {
+		next = time.Date(now.Year(), now.Month(), now.Day()+1, h, m, sec, 0, now.Location())
+	}
+
+	return next
+}
@@ -0,0 +1,131 @@
+package notify_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	"github.com/SuddenGunter/hsd/event"
+	"github.com/SuddenGunter/hsd/notify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeEvents []event.Event
+
+func (e fakeEvents) Query(f event.Filter) event.Page {
+	var p event.Page
+
+	for i := len(e) - 1; i >= 0; i-- {
+		if f.Match(e[i]) {
+			p.Events = append(p.Events, e[i])
+		}
+	}
+
+	return p
+}
+
+type fakeDevices []alarm.DeviceStatus
+
+func (d fakeDevices) Statuses(context.Context) ([]alarm.DeviceStatus, error) {
+	return d, nil
+}
+
+func TestDigest_Build(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 1, 2, 8, 0, 0, 0, time.Local)
+	offline, online := false, true
+
+	events := fakeEvents{
+		{Time: now.Add(-30 * time.Hour), Type: event.TypeAlarm, Device: "door1", Message: "too old"},
+		{Time: now.Add(-12 * time.Hour), Type: event.TypeStateChange, State: "armed_away", Actor: "alice", Source: "api"},
+		{Time: now.Add(-11 * time.Hour), Type: event.TypeAvailability, Device: "window1", Available: &offline},
+		{Time: now.Add(-10 * time.Hour), Type: event.TypeAvailability, Device: "window1", Available: &online},
+		{Time: now.Add(-9 * time.Hour), Type: event.TypeAvailability, Device: "window1", Available: &offline},
+		{Time: now.Add(-8 * time.Hour), Type: event.TypeAlarm, Device: "door1", Message: "opened"},
+		{Time: now.Add(-time.Hour), Type: event.TypeStateChange, State: "disarmed", Actor: "bob", Source: "homeassistant"},
+	}
+
+	updated := now.Add(-time.Minute)
+	devices := fakeDevices{
+		{Name: "door1", Battery: 90, LastUpdated: &updated},
+		{Name: "window1", Battery: 15, LastUpdated: &updated},
+		{Name: "garage", Battery: 0},
+	}
+
+	s := &fakeSender{}
+	d := notify.NewDigest(s, events, devices, notify.DigestOptions{LowBattery: 20}, discard)
+
+	require.NoError(t, d.Send(context.Background(), now))
+	assert.Equal(t, []string{`digest: daily digest, alarms: 1
+alarm: 20:00 armed_away by alice via api
+alarm: 07:00 disarmed by bob via homeassistant
+window1: went offline 2 times
+window1: battery at 15%`}, s.messages())
+}
+
+func TestDigest_NothingToReport(t *testing.T) {
+	t.Parallel()
+
+	s := &fakeSender{}
+	d := notify.NewDigest(s, fakeEvents{}, fakeDevices{}, notify.DigestOptions{LowBattery: 20}, discard)
+
+	require.NoError(t, d.Send(context.Background(), time.Now()))
+	assert.Equal(t, []string{"digest: daily digest: nothing to report"}, s.messages())
+}
+
+func TestDigest_BuildPages(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 1, 2, 8, 0, 0, 0, time.Local)
+
+	store, err := event.Open("", 48*time.Hour, 0, discard)
+	require.NoError(t, err)
+
+	store.Record(event.Event{Time: now.Add(-12 * time.Hour), Type: event.TypeStateChange, State: "armed_away", Actor: "alice", Source: "api"})
+
+	// more alarms than a single query returns, all newer than the state change
+	for i := range 1500 {
+		store.Record(event.Event{Time: now.Add(-time.Hour + time.Duration(i)*time.Second), Type: event.TypeAlarm, Device: "door1", Message: "opened"})
+	}
+
+	d := notify.NewDigest(&fakeSender{}, store, fakeDevices{}, notify.DigestOptions{}, discard)
+
+	msg, err := d.Build(context.Background(), now)
+	require.NoError(t, err)
+	assert.Equal(t, "1500", msg.Args["alarms"])
+	require.Len(t, msg.Items, 1)
+	assert.Equal(t, "20:00 armed_away by alice via api", msg.Items[0].String())
+}
+
+func TestDigest_Next(t *testing.T) {
+	t.Parallel()
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	d := notify.NewDigest(&fakeSender{}, fakeEvents{}, fakeDevices{}, notify.DigestOptions{At: 8*time.Hour + 30*time.Minute}, discard)
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Time
+	}{
+		{name: "later today", now: time.Date(2025, 1, 2, 7, 0, 0, 0, berlin), want: time.Date(2025, 1, 2, 8, 30, 0, 0, berlin)},
+		{name: "tomorrow", now: time.Date(2025, 1, 2, 8, 30, 0, 0, berlin), want: time.Date(2025, 1, 3, 8, 30, 0, 0, berlin)},
+		{name: "dst starts", now: time.Date(2025, 3, 30, 1, 0, 0, 0, berlin), want: time.Date(2025, 3, 30, 8, 30, 0, 0, berlin)},
+		{name: "dst ends", now: time.Date(2025, 10, 25, 9, 0, 0, 0, berlin), want: time.Date(2025, 10, 26, 8, 30, 0, 0, berlin)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := d.Next(tt.now)
+			assert.True(t, tt.want.Equal(got), "got %s, want %s", got, tt.want)
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/SuddenGunter/hsd/metrics"
+	"github.com/SuddenGunter/hsd/notify/message"
+)
+
+var (
+	dispatchDropped = metrics.NewCounter("hsd_notify_dropped_total", "Notifications dropped because the dispatch queue stayed full.")
+	enqueueWait     = metrics.NewHistogram("hsd_notify_enqueue_wait_seconds", "Time spent waiting for room in the dispatch queue.", metrics.DefaultBuckets)
+)
+
+// Notifier is told about alarm events. Notify must not return before it is done with the message.
+type Notifier interface {
+	Notify(m message.Message)
+}
+
+// DispatcherOptions configure the Dispatcher.
+type DispatcherOptions struct {
+	// Workers is the number of goroutines delivering notifications.
+	Workers int
+	// QueueSize is the number of notifications each worker can hold.
+	QueueSize int
+	// EnqueueTimeout is how long Notify waits for room in a full queue before dropping the notification.
+	EnqueueTimeout time.Duration
+}
+
+// Dispatcher hands notifications to a pool of workers, so a slow notifier does not block device goroutines.
+// Notifications of a device are always handled by the same worker, keeping them in order.
+type Dispatcher struct {
+	next    Notifier
+	queues  []chan message.Message
+	timeout time.Duration
+
+	mux    *sync.RWMutex
+	closed bool
+	wg     *sync.WaitGroup
+
+	l *slog.Logger
+}
+
+// NewDispatcher returns a new Dispatcher and starts its workers.
+func NewDispatcher(next Notifier, opts DispatcherOptions, l *slog.Logger) *Dispatcher {
+	d := &Dispatcher{
+		next:    next,
+		queues:  make([]chan message.Message, max(opts.Workers, 1)),
+		timeout: opts.EnqueueTimeout,
+		mux:     &sync.RWMutex{},
+		wg:      &sync.WaitGroup{},
+		l:       l,
+	}
+
+	for i := range d.queues {
+		d.queues[i] = make(chan message.Message, opts.QueueSize)
+		d.wg.Add(1)
+
+		go d.work(d.queues[i])
+	}
+
+	return d
+}
+
+// Notify queues the notification. If the queue is full, it waits up to the enqueue timeout
+// and drops the notification.
+func (d *Dispatcher) Notify(m message.Message) {
+	d.mux.RLock()
+	defer d.mux.RUnlock()
+
+	if d.closed {
+		d.l.Error("notification dropped, dispatcher is closed", "device", m.Device, "message", m)
+		dispatchDropped.Inc()
+
+		return
+	}
+
+	q := d.queues[d.shard(m.Device)]
+
+	select {
+	case q <- m:
+		return
+	default:
+	}
+
+	start := time.Now()
+	t := time.NewTimer(d.timeout)
+
+	defer t.Stop()
+
+	select {
+	case q <- m:
+		enqueueWait.Observe(time.Since(start).Seconds())
+	case <-t.C:
+		enqueueWait.Observe(time.Since(start).Seconds())
+		d.l.Error("notification dropped, dispatch queue is full", "device", m.Device, "message", m)
+		dispatchDropped.Inc()
+	}
+}
+
+// Len returns the number of queued notifications.
+func (d *Dispatcher) Len() int {
+	n := 0
+	for _, q := range d.queues {
+		n += len(q)
+	}
+
+	return n
+}
+
+// Close stops accepting notifications and waits until queued ones are handled or ctx is done.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mux.Lock()
+
+	if !d.closed {
+		d.closed = true
+
+		for _, q := range d.queues {
+			close(q)
+		}
+	}
+
+	d.mux.Unlock()
+
+	done := make(chan struct{})
+
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("dispatcher: %d notifications not handled: %w", d.Len(), ctx.Err())
+	}
+}
+
+func (d *Dispatcher) work(q <-chan message.Message) {
+	defer d.wg.Done()
+
+	for m := range q {
+		d.next.Notify(m)
+	}
+}
+
+func (d *Dispatcher) shard(device string) int {
+	h := fnv.New32a()
+	h.Write([]byte(device))
+
+	//nolint:gosec // the number of workers fits in uint32
+	return int(h.Sum32() % uint32(len(d.queues)))
+}
@@ -0,0 +1,107 @@
+package notify_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SuddenGunter/hsd/notify"
+	"github.com/SuddenGunter/hsd/notify/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingNotifier records notifications after the test releases it.
+type blockingNotifier struct {
+	release chan struct{}
+
+	mux      sync.Mutex
+	notified []string
+}
+
+func (n *blockingNotifier) Notify(m message.Message) {
+	<-n.release
+
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	n.notified = append(n.notified, m.Device+": "+m.String())
+}
+
+func (n *blockingNotifier) messages() []string {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	return append([]string(nil), n.notified...)
+}
+
+func TestDispatcher_DoesNotBlockCallers(t *testing.T) {
+	t.Parallel()
+
+	n := &blockingNotifier{release: make(chan struct{})}
+	d := notify.NewDispatcher(n, notify.DispatcherOptions{Workers: 2, QueueSize: 10, EnqueueTimeout: time.Second}, discard)
+
+	done := make(chan struct{})
+
+	go func() {
+		for i := range 5 {
+			d.Notify(alarmMessage("door1", fmt.Sprintf("event %d", i)))
+		}
+
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Notify blocked on a slow notifier")
+	}
+
+	close(n.release)
+	require.NoError(t, d.Close(context.Background()))
+
+	// notifications of a device keep their order
+	assert.Equal(t, []string{"door1: event 0", "door1: event 1", "door1: event 2", "door1: event 3", "door1: event 4"}, n.messages())
+}
+
+func TestDispatcher_DropsWhenFull(t *testing.T) {
+	t.Parallel()
+
+	n := &blockingNotifier{release: make(chan struct{})}
+	d := notify.NewDispatcher(n, notify.DispatcherOptions{Workers: 1, QueueSize: 1, EnqueueTimeout: 10 * time.Millisecond}, discard)
+
+	// the worker holds the first one, the queue holds the second
+	d.Notify(alarmMessage("door1", "first"))
+	require.Eventually(t, func() bool { return d.Len() == 0 }, time.Second, time.Millisecond)
+	d.Notify(alarmMessage("door1", "second"))
+
+	start := time.Now()
+	d.Notify(alarmMessage("door1", "third"))
+	assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond, "Notify waits for room before dropping")
+
+	close(n.release)
+	require.NoError(t, d.Close(context.Background()))
+	assert.Equal(t, []string{"door1: first", "door1: second"}, n.messages())
+
+	// closed dispatchers drop notifications
+	d.Notify(alarmMessage("door1", "fourth"))
+	assert.Len(t, n.messages(), 2)
+}
+
+func TestDispatcher_CloseTimeout(t *testing.T) {
+	t.Parallel()
+
+	n := &blockingNotifier{release: make(chan struct{})}
+	d := notify.NewDispatcher(n, notify.DispatcherOptions{Workers: 1, QueueSize: 10}, discard)
+
+	d.Notify(alarmMessage("door1", "first"))
+	d.Notify(alarmMessage("door1", "second"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	require.ErrorIs(t, d.Close(ctx), context.DeadlineExceeded)
+	close(n.release)
+}
@@ -0,0 +1,141 @@
+package message
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed en.yaml
+var englishYAML []byte
+
+// english is the built-in catalog, other catalogs fall back to it.
+var english = mustParse(englishYAML, nil)
+
+// Catalog holds the texts of messages in one language.
+type Catalog struct {
+	// Messages are text/template texts by message key. Templates get message args, e.g. {{.actor}}.
+	Messages map[string]string `yaml:"messages"`
+	// Phrases translate values passed to the t template function, e.g. alarm reasons and device names.
+	Phrases map[string]string `yaml:"phrases"`
+	// Layouts are templates of whole notifications by "kind.severity", "kind", "severity" or "default",
+	// the first one found is used. They are written in the notifier's format, e.g. MarkdownV2.
+	Layouts map[string]string `yaml:"layouts"`
+	// Icons by severity, available to layouts as {{.Icon}}.
+	Icons map[Severity]string `yaml:"icons"`
+
+	messages map[string]*template.Template
+}
+
+// English returns the built-in catalog.
+func English() *Catalog {
+	return english
+}
+
+// LoadCatalog loads a catalog from a YAML file. Entries it doesn't have are taken from the English catalog.
+func LoadCatalog(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("catalog: %w", err)
+	}
+
+	c, err := parse(data, english)
+	if err != nil {
+		return nil, fmt.Errorf("catalog %s: %w", path, err)
+	}
+
+	return c, nil
+}
+
+func mustParse(data []byte, base *Catalog) *Catalog {
+	c, err := parse(data, base)
+	if err != nil {
+		panic(err)
+	}
+
+	return c
+}
+
+// parse parses a catalog, taking entries it doesn't have from base, if set.
+func parse(data []byte, base *Catalog) (*Catalog, error) {
+	var c Catalog
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	if err := dec.Decode(&c); err != nil {
+		return nil, err
+	}
+
+	if base != nil {
+		c.Messages = merge(base.Messages, c.Messages)
+		c.Phrases = merge(base.Phrases, c.Phrases)
+		c.Layouts = merge(base.Layouts, c.Layouts)
+		c.Icons = merge(base.Icons, c.Icons)
+	}
+
+	c.messages = make(map[string]*template.Template, len(c.Messages))
+
+	for key, text := range c.Messages {
+		t, err := template.New(key).Funcs(template.FuncMap{"t": c.translate}).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("messages: %w", err)
+		}
+
+		c.messages[key] = t
+	}
+
+	return &c, nil
+}
+
+// text renders the text of the message. Unknown keys and failing templates fall back to the key and args,
+// so a broken catalog never loses a notification.
+func (c *Catalog) text(m Message) string {
+	t, ok := c.messages[m.Key]
+	if !ok {
+		return fallback(m)
+	}
+
+	var sb strings.Builder
+	if err := t.Execute(&sb, m.Args); err != nil {
+		return fallback(m)
+	}
+
+	return sb.String()
+}
+
+// translate returns the translation of the phrase, or the phrase itself.
+func (c *Catalog) translate(phrase string) string {
+	if t, ok := c.Phrases[phrase]; ok {
+		return t
+	}
+
+	return phrase
+}
+
+func fallback(m Message) string {
+	s := m.Key
+	for _, k := range slices.Sorted(maps.Keys(m.Args)) {
+		s += fmt.Sprintf(" %s=%s", k, m.Args[k])
+	}
+
+	return s
+}
+
+func merge[K comparable, V any](base, override map[K]V) map[K]V {
+	m := maps.Clone(base)
+	if m == nil {
+		m = make(map[K]V, len(override))
+	}
+
+	maps.Copy(m, override)
+
+	return m
+}
@@ -0,0 +1,97 @@
+// Package message describes notifications independently of their language and of the notifier delivering them.
+package message
+
+import (
+	"strings"
+	"time"
+)
+
+// Severity tells how urgent a message is.
+type Severity string
+
+// Severities from the most urgent.
+const (
+	Critical Severity = "critical"
+	Warning  Severity = "warning"
+	Info     Severity = "info"
+)
+
+// Severities lists all severities.
+var Severities = []Severity{Critical, Warning, Info}
+
+// Keys of messages in catalogs. The part before the dot is the kind of the message.
+const (
+	// KeyAlarm is a device alarm, args: reason.
+	KeyAlarm = "alarm.device"
+	// KeyAlarmManual is a manually triggered alarm, args: actor.
+	KeyAlarmManual = "alarm.manual"
+	// KeySuppressed summarizes device alarms held back by a throttle window, args: reason, suppressed.
+	KeySuppressed = "alarm.suppressed"
+	// KeyArming is sent when the exit delay starts, args: mode, delay, actor, bypassed.
+	KeyArming = "state.arming"
+	// KeyArmed is sent when the alarm is armed, args: mode, actor (empty after the exit delay), bypassed.
+	KeyArmed = "state.armed"
+	// KeyDisarmed is sent when the alarm is disarmed, args: actor.
+	KeyDisarmed = "state.disarmed"
+	// KeyBypassed is sent when a device is bypassed, args: duration (empty until disarmed), actor, reason.
+	KeyBypassed = "bypass.started"
+	// KeyBypassExpired is sent when a bypass expires.
+	KeyBypassExpired = "bypass.expired"
+	// KeyBypassDisarmed is sent when a bypass ends because the alarm was disarmed.
+	KeyBypassDisarmed = "bypass.disarmed"
+	// KeyBypassEnded is sent when a bypass is ended by a user, args: actor.
+	KeyBypassEnded = "bypass.ended"
+	// KeyLockout is sent when an API client is locked out, args: client, lockout, failures.
+	KeyLockout = "security.lockout"
+	// KeyBatch summarizes Items, args: count.
+	KeyBatch = "summary.batch"
+	// KeyDigest is the daily digest listing Items, args: alarms (empty if none), empty (set if there is nothing to report).
+	KeyDigest = "summary.digest"
+	// KeyDigestState is an alarm state change listed in the digest, args: time, state, actor.
+	KeyDigestState = "digest.state"
+	// KeyDigestOffline is a device that went offline, listed in the digest, args: count.
+	KeyDigestOffline = "digest.offline"
+	// KeyDigestBattery is a device with low battery, listed in the digest, args: battery.
+	KeyDigestBattery = "digest.battery"
+	// KeyTest is sent by hsd test-notify, args: host, version.
+	KeyTest = "test.notification"
+)
+
+// Message is a notification about the device. Its text is rendered from the catalog entry Key with Args.
+type Message struct {
+	Key      string            `json:"key"`
+	Severity Severity          `json:"severity"`
+	Device   string            `json:"device"`
+	Args     map[string]string `json:"args,omitempty"`
+	// Items are the messages a summary consists of.
+	Items []Message `json:"items,omitempty"`
+	// Time is when the message was raised, Delayed is set when it is delivered late.
+	Time    time.Time `json:"time,omitzero"`
+	Delayed bool      `json:"delayed,omitempty"`
+}
+
+// New returns a message with args given as alternating names and values, like slog attributes.
+func New(key string, severity Severity, device string, args ...string) Message {
+	m := Message{Key: key, Severity: severity, Device: device}
+
+	if len(args) > 0 {
+		m.Args = make(map[string]string, len(args)/2)
+
+		for i := 0; i+1 < len(args); i += 2 {
+			m.Args[args[i]] = args[i+1]
+		}
+	}
+
+	return m
+}
+
+// Kind returns the kind of the message, e.g. alarm or state.
+func (m Message) Kind() string {
+	kind, _, _ := strings.Cut(m.Key, ".")
+	return kind
+}
+
+// String returns the English text of the message, without the device.
+func (m Message) String() string {
+	return english.text(m)
+}
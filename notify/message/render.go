@@ -0,0 +1,158 @@
+package message
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ErrUnknownFormat is returned for unsupported formats.
+var ErrUnknownFormat = errors.New("unknown format")
+
+// Format is the markup notifications are written in. Texts and device names are escaped for it.
+type Format string
+
+// Supported formats, named like telegram parse modes.
+const (
+	FormatText       Format = ""
+	FormatMarkdownV2 Format = "MarkdownV2"
+	FormatHTML       Format = "HTML"
+)
+
+// ParseFormat parses a format name.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatText, FormatMarkdownV2, FormatHTML:
+		return f, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownFormat, s)
+	}
+}
+
+// DeviceInfo returns the friendly name and location of a device, empty if unknown.
+type DeviceInfo func(device string) (label, location string)
+
+// Renderer renders messages with a catalog.
+type Renderer struct {
+	catalog *Catalog
+	format  Format
+	devices DeviceInfo
+	layouts map[string]*template.Template
+}
+
+// layout is the data of layout templates.
+type layout struct {
+	Icon     string
+	Device   string
+	Location string
+	Text     string
+	Kind     string
+	Severity Severity
+	Delayed  bool
+	Time     string
+}
+
+// NewRenderer returns a new Renderer. Devices may be nil.
+func NewRenderer(c *Catalog, f Format, devices DeviceInfo) (*Renderer, error) {
+	if devices == nil {
+		devices = func(string) (string, string) { return "", "" }
+	}
+
+	r := &Renderer{catalog: c, format: f, devices: devices, layouts: make(map[string]*template.Template, len(c.Layouts))}
+
+	for name, text := range c.Layouts {
+		t, err := template.New(name).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("layouts: %w", err)
+		}
+
+		r.layouts[name] = t
+	}
+
+	for _, name := range []string{"default", "item"} {
+		if r.layouts[name] == nil {
+			return nil, fmt.Errorf("layouts: %s is required", name)
+		}
+	}
+
+	return r, nil
+}
+
+// Format returns the format of rendered messages.
+func (r *Renderer) Format() Format {
+	return r.format
+}
+
+// Render returns the notification text of the message.
+func (r *Renderer) Render(m Message) (string, error) {
+	name := "default"
+
+	for _, n := range []string{m.Kind() + "." + string(m.Severity), m.Kind(), string(m.Severity)} {
+		if r.layouts[n] != nil {
+			name = n
+			break
+		}
+	}
+
+	text := r.escape(r.catalog.text(m))
+
+	// summary lines are rendered with the item layout
+	for _, item := range m.Items {
+		line, err := r.execute("item", item, r.escape(r.catalog.text(item)))
+		if err != nil {
+			return "", err
+		}
+
+		text += "\n" + line
+	}
+
+	return r.execute(name, m, text)
+}
+
+func (r *Renderer) execute(name string, m Message, text string) (string, error) {
+	label, location := r.devices(m.Device)
+	if label == "" {
+		label = r.catalog.translate(m.Device)
+	}
+
+	data := layout{
+		Icon:     r.catalog.Icons[m.Severity],
+		Device:   r.escape(label),
+		Location: r.escape(location),
+		Text:     text,
+		Kind:     m.Kind(),
+		Severity: m.Severity,
+		Delayed:  m.Delayed,
+	}
+
+	if !m.Time.IsZero() {
+		data.Time = r.escape(m.Time.Local().Format(time.DateTime))
+	}
+
+	var sb strings.Builder
+	if err := r.layouts[name].Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("render %s: %w", m.Key, err)
+	}
+
+	return sb.String(), nil
+}
+
+// markdownV2Special are characters telegram requires to be escaped in MarkdownV2.
+var markdownV2Special = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`, "~", `\~`, "`", "\\`",
+	">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`, "|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+func (r *Renderer) escape(s string) string {
+	switch r.format {
+	case FormatMarkdownV2:
+		return markdownV2Special.Replace(s)
+	case FormatHTML:
+		return html.EscapeString(s)
+	default:
+		return s
+	}
+}
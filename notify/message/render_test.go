@@ -0,0 +1,160 @@
+package message_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SuddenGunter/hsd/notify/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func devices(device string) (string, string) {
+	if device == "0x00158d0001a2b3c4" {
+		return "Front door", "Hall"
+	}
+
+	return "", ""
+}
+
+func TestRenderer_English(t *testing.T) {
+	t.Parallel()
+
+	r, err := message.NewRenderer(message.English(), message.FormatText, devices)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		msg  message.Message
+		want string
+	}{
+		{
+			name: "device alarm",
+			msg:  message.New(message.KeyAlarm, message.Critical, "0x00158d0001a2b3c4", "reason", "opened"),
+			want: "🚨 Front door · Hall: opened",
+		},
+		{
+			name: "armed",
+			msg:  message.New(message.KeyArmed, message.Info, "alarm", "mode", "away", "actor", "alice via api", "bypassed", "window1 (open)"),
+			want: "ℹ️ alarm: armed (away) by alice via api, bypassed: window1 (open)",
+		},
+		{
+			name: "armed after the exit delay",
+			msg:  message.New(message.KeyArmed, message.Info, "alarm", "mode", "night"),
+			want: "ℹ️ alarm: armed (night)",
+		},
+		{
+			name: "delayed",
+			msg: message.Message{
+				Key: message.KeyDisarmed, Severity: message.Info, Device: "alarm", Args: map[string]string{"actor": "bob via api"},
+				Time: time.Date(2025, 1, 1, 20, 0, 0, 0, time.Local), Delayed: true,
+			},
+			want: "ℹ️ alarm: disarmed by bob via api · delayed, from 2025-01-01 20:00:00",
+		},
+		{
+			name: "batch",
+			msg: message.Message{
+				Key: message.KeyBatch, Severity: message.Critical, Device: "hsd", Args: map[string]string{"count": "2"},
+				Items: []message.Message{
+					message.New(message.KeyAlarm, message.Critical, "0x00158d0001a2b3c4", "reason", "unavailable"),
+					message.New(message.KeyBypassExpired, message.Warning, "window1"),
+				},
+			},
+			want: "🚨 hsd: 2 notifications:\nFront door: unavailable\nwindow1: bypass expired, monitoring resumed",
+		},
+		{
+			name: "digest",
+			msg: message.Message{
+				Key: message.KeyDigest, Severity: message.Info, Device: "digest", Args: map[string]string{"alarms": "3"},
+				Items: []message.Message{
+					message.New(message.KeyDigestState, message.Info, "alarm", "time", "07:00", "state", "disarmed", "actor", "bob via api"),
+					message.New(message.KeyDigestOffline, message.Info, "window1", "count", "2"),
+					message.New(message.KeyDigestBattery, message.Info, "0x00158d0001a2b3c4", "battery", "15"),
+				},
+			},
+			want: "ℹ️ digest: daily digest, alarms: 3\nalarm: 07:00 disarmed by bob via api\nwindow1: went offline 2 times\nFront door: battery at 15%",
+		},
+		{
+			name: "unknown key",
+			msg:  message.New("alarm.unknown", message.Critical, "door1", "b", "2", "a", "1"),
+			want: "🚨 door1: alarm.unknown a=1 b=2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := r.Render(tt.msg)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRenderer_Escaping(t *testing.T) {
+	t.Parallel()
+
+	msg := message.New(message.KeyBypassed, message.Warning, "door_1", "actor", "alice via api", "reason", "<painting> (2h).")
+
+	md, err := message.NewRenderer(message.English(), message.FormatMarkdownV2, nil)
+	require.NoError(t, err)
+
+	got, err := md.Render(msg)
+	require.NoError(t, err)
+	assert.Equal(t, `⚠️ door\_1: bypassed until disarmed by alice via api: <painting\> \(2h\)\.`, got)
+
+	h, err := message.NewRenderer(message.English(), message.FormatHTML, nil)
+	require.NoError(t, err)
+
+	got, err = h.Render(msg)
+	require.NoError(t, err)
+	assert.Equal(t, `⚠️ door_1: bypassed until disarmed by alice via api: &lt;painting&gt; (2h).`, got)
+}
+
+func TestLoadCatalog(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "uk.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+messages:
+  alarm.device: "{{t .reason}}"
+  state.disarmed: "знято з охорони: {{.actor}}"
+phrases:
+  opened: "відчинено"
+  alarm: "сигналізація"
+layouts:
+  alarm.critical: "*{{.Icon}} {{.Device}}*: {{.Text}}"
+`), 0o600))
+
+	c, err := message.LoadCatalog(path)
+	require.NoError(t, err)
+
+	r, err := message.NewRenderer(c, message.FormatMarkdownV2, devices)
+	require.NoError(t, err)
+
+	got, err := r.Render(message.New(message.KeyAlarm, message.Critical, "0x00158d0001a2b3c4", "reason", "opened"))
+	require.NoError(t, err)
+	assert.Equal(t, "*🚨 Front door*: відчинено", got)
+
+	got, err = r.Render(message.New(message.KeyDisarmed, message.Info, "alarm", "actor", "bob"))
+	require.NoError(t, err)
+	assert.Equal(t, "ℹ️ сигналізація: знято з охорони: bob", got)
+
+	// untranslated messages fall back to English
+	got, err = r.Render(message.New(message.KeyBypassExpired, message.Warning, "door1"))
+	require.NoError(t, err)
+	assert.Equal(t, `⚠️ door1: bypass expired, monitoring resumed`, got)
+
+	require.NoError(t, os.WriteFile(path, []byte("messages:\n  state.armed: \"{{.mode\"\n"), 0o600))
+
+	_, err = message.LoadCatalog(path)
+	require.Error(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte("mesages: {}\n"), 0o600))
+
+	_, err = message.LoadCatalog(path)
+	require.Error(t, err, "unknown fields are rejected")
+}
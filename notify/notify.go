@@ -0,0 +1,39 @@
+// Package notify sits between hsd and notifiers such as telegram, shaping what gets delivered and when.
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/SuddenGunter/hsd/notify/message"
+)
+
+// ErrRejected is wrapped by Sender errors for messages that can never be delivered, e.g. refused by the API.
+// Such messages are dropped instead of retried.
+var ErrRejected = errors.New("message rejected")
+
+// Sender delivers the message and returns the delivery error.
+type Sender interface {
+	Send(m message.Message) error
+}
+
+// RetryAfterError is returned by a rate limited Sender, the delivery may be retried after the delay.
+type RetryAfterError struct {
+	After time.Duration
+	Err   error
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("retry after %s: %v", e.After, e.Err)
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// moreUrgent reports whether severity a is more urgent than b.
+func moreUrgent(a, b message.Severity) bool {
+	return slices.Index(message.Severities, a) < slices.Index(message.Severities, b)
+}
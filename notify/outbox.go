@@ -0,0 +1,342 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	"github.com/SuddenGunter/hsd/metrics"
+	"github.com/SuddenGunter/hsd/notify/message"
+)
+
+// maxPending limits the outbox size. When it is full, the oldest of the least urgent notifications is dropped.
+const maxPending = 1000
+
+var (
+	outboxDropped  = metrics.NewCounter("hsd_outbox_dropped_total", "Notifications dropped because the outbox was full.")
+	outboxRejected = metrics.NewCounter("hsd_outbox_rejected_total", "Notifications dropped because the notifier rejected them.")
+)
+
+// OutboxOptions configure the Outbox.
+type OutboxOptions struct {
+	// Path of the outbox file, notifications are kept in memory only if empty.
+	Path string
+	// MinBackoff and MaxBackoff bound the exponential delay between delivery attempts.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// LateAfter is the delay after which delivered notifications are marked as delayed, showing their original time.
+	LateAfter time.Duration
+	// Clock schedules retries, alarm.SystemClock if nil.
+	Clock alarm.Clock
+}
+
+// Outbox is a durable notification queue. Notifications are saved to a file before they are delivered
+// one by one in order, retrying with exponential backoff until the delivery succeeds.
+// Notifications the Sender rejects with ErrRejected are dropped, so they don't hold up the ones behind them.
+// Notifications that were not delivered are loaded again on start.
+type Outbox struct {
+	next Sender
+	opts OutboxOptions
+
+	mux     *sync.Mutex
+	pending []pendingNotification
+	nextID  uint64
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+
+	l *slog.Logger
+}
+
+type pendingNotification struct {
+	ID      uint64          `json:"id"`
+	Message message.Message `json:"message"`
+}
+
+// OpenOutbox loads notifications that were not delivered and starts delivering them.
+func OpenOutbox(next Sender, opts OutboxOptions, l *slog.Logger) (*Outbox, error) {
+	if opts.Clock == nil {
+		opts.Clock = alarm.SystemClock{}
+	}
+
+	o := &Outbox{
+		next:   next,
+		opts:   opts,
+		mux:    &sync.Mutex{},
+		nextID: 1,
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		l:      l,
+	}
+
+	if err := o.load(); err != nil {
+		return nil, fmt.Errorf("outbox: %w", err)
+	}
+
+	if len(o.pending) > 0 {
+		l.Info("outbox has undelivered notifications", "count", len(o.pending))
+	}
+
+	go o.run()
+
+	return o, nil
+}
+
+// Send queues the notification. It returns an error if the notification could not be saved,
+// in which case it is still delivered unless hsd restarts first.
+func (o *Outbox) Send(m message.Message) error {
+	if m.Time.IsZero() {
+		m.Time = time.Now()
+	}
+
+	o.mux.Lock()
+
+	o.pending = append(o.pending, pendingNotification{ID: o.nextID, Message: m})
+	o.nextID++
+
+	if len(o.pending) > maxPending {
+		i := o.evictable()
+		o.l.Error("outbox is full, dropping a notification", "message", o.pending[i].Message)
+		outboxDropped.Inc()
+
+		o.pending = slices.Delete(o.pending, i, i+1)
+	}
+
+	err := o.save()
+	o.mux.Unlock()
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+
+	return err
+}
+
+// Pending returns the number of notifications waiting for delivery.
+func (o *Outbox) Pending() int {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	return len(o.pending)
+}
+
+// Close makes one more attempt to deliver queued notifications and stops delivery, waiting until ctx is done
+// at most. Undelivered notifications stay in the file, or are dropped if the outbox is kept in memory only.
+func (o *Outbox) Close(ctx context.Context) error {
+	close(o.stop)
+
+	var err error
+
+	select {
+	case <-o.done:
+	case <-ctx.Done():
+		err = fmt.Errorf("outbox: delivery not finished: %w", ctx.Err())
+	}
+
+	if n := o.Pending(); n > 0 {
+		if o.opts.Path == "" {
+			o.l.Error("outbox is kept in memory only, dropping undelivered notifications", "count", n)
+		} else {
+			o.l.Info("undelivered notifications are kept for the next start", "count", n)
+		}
+	}
+
+	return err
+}
+
+func (o *Outbox) run() {
+	defer close(o.done)
+
+	backoff := time.Duration(0)
+
+	for {
+		n, ok := o.head()
+		if !ok {
+			select {
+			case <-o.wake:
+				continue
+			case <-o.stop:
+				return
+			}
+		}
+
+		err := o.next.Send(o.annotate(n.Message))
+
+		switch {
+		case err == nil:
+			o.remove(n.ID)
+
+			backoff = 0
+
+			continue
+		case errors.Is(err, ErrRejected):
+			o.l.Error("notification rejected, dropping it", "message", n.Message, "err", err)
+			outboxRejected.Inc()
+			o.remove(n.ID)
+
+			continue
+		}
+
+		if o.stopped() {
+			o.l.Warn("notification delivery failed on shutdown", "device", n.Message.Device, "err", err)
+			return
+		}
+
+		backoff = min(max(2*backoff, o.opts.MinBackoff), o.opts.MaxBackoff)
+		wait := backoff
+
+		// the notifier knows better when it accepts messages again
+		var retryAfter *RetryAfterError
+		if errors.As(err, &retryAfter) {
+			wait = retryAfter.After
+		}
+
+		o.l.Warn("notification delivery failed, retrying", "device", n.Message.Device, "retry_in", wait, "err", err)
+
+		retry := make(chan struct{})
+		t := o.opts.Clock.AfterFunc(wait, func() { close(retry) })
+
+		select {
+		case <-retry:
+		case <-o.stop:
+			// try once more before stopping
+			t.Stop()
+		}
+	}
+}
+
+// stopped reports whether Close was called, delivery stops after the next failed attempt.
+func (o *Outbox) stopped() bool {
+	select {
+	case <-o.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (o *Outbox) head() (pendingNotification, bool) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	if len(o.pending) == 0 {
+		return pendingNotification{}, false
+	}
+
+	return o.pending[0], true
+}
+
+// remove drops the head notification after a delivery attempt.
+func (o *Outbox) remove(id uint64) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	// the notification may have been dropped while it was being delivered
+	if len(o.pending) == 0 || o.pending[0].ID != id {
+		return
+	}
+
+	o.pending = o.pending[1:]
+
+	if err := o.save(); err != nil {
+		// the notification is delivered again after a restart
+		o.l.Error("failed to save outbox", "err", err)
+	}
+}
+
+// evictable returns the index of the oldest notification with the lowest severity. Must be called with o.mux held.
+func (o *Outbox) evictable() int {
+	i := 0
+
+	for j, n := range o.pending {
+		if moreUrgent(o.pending[i].Message.Severity, n.Message.Severity) {
+			i = j
+		}
+	}
+
+	return i
+}
+
+// annotate marks notifications delivered late, so their original time is shown.
+func (o *Outbox) annotate(m message.Message) message.Message {
+	m.Delayed = o.opts.LateAfter > 0 && time.Since(m.Time) >= o.opts.LateAfter
+	return m
+}
+
+func (o *Outbox) load() error {
+	if o.opts.Path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(o.opts.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("load: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &o.pending); err != nil {
+		return fmt.Errorf("load: %w", err)
+	}
+
+	for _, n := range o.pending {
+		o.nextID = max(o.nextID, n.ID+1)
+	}
+
+	return nil
+}
+
+// save atomically replaces the file with pending notifications. Must be called with o.mux held.
+func (o *Outbox) save() error {
+	if o.opts.Path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(o.pending)
+	if err != nil {
+		return fmt.Errorf("outbox: save: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(o.opts.Path), filepath.Base(o.opts.Path)+".*")
+	if err != nil {
+		return fmt.Errorf("outbox: save: %w", err)
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+
+		return fmt.Errorf("outbox: save: %w", err)
+	}
+
+	// an alert must survive a power cut right after it was queued
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+
+		return fmt.Errorf("outbox: save: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("outbox: save: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), o.opts.Path); err != nil {
+		return fmt.Errorf("outbox: save: %w", err)
+	}
+
+	return nil
+}
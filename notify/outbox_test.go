@@ -0,0 +1,287 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SuddenGunter/hsd/alarm/alarmtest"
+	"github.com/SuddenGunter/hsd/notify"
+	"github.com/SuddenGunter/hsd/notify/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakySender fails until it is fixed, asking to retry later if retryAfter is set. It always rejects reject messages.
+type flakySender struct {
+	fakeSender
+
+	mux        sync.Mutex
+	attempts   int
+	broken     bool
+	retryAfter time.Duration
+	reject     string
+}
+
+func (s *flakySender) Send(m message.Message) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.attempts++
+
+	switch {
+	case m.Key == s.reject:
+		return fmt.Errorf("%w: can't parse entities", notify.ErrRejected)
+	case s.broken && s.retryAfter > 0:
+		return &notify.RetryAfterError{After: s.retryAfter, Err: errors.New("too many requests")}
+	case s.broken:
+		return errors.New("no internet")
+	}
+
+	return s.fakeSender.Send(m)
+}
+
+func (s *flakySender) fix() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.broken = false
+}
+
+func (s *flakySender) tries() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.attempts
+}
+
+// blockingSender blocks deliveries until the test releases it.
+type blockingSender struct {
+	release chan struct{}
+}
+
+func (s *blockingSender) Send(message.Message) error {
+	<-s.release
+	return nil
+}
+
+func outboxOptions(t *testing.T, c *alarmtest.Clock) notify.OutboxOptions {
+	t.Helper()
+
+	return notify.OutboxOptions{
+		Path:       filepath.Join(t.TempDir(), "outbox.json"),
+		MinBackoff: time.Second,
+		MaxBackoff: 4 * time.Second,
+		LateAfter:  time.Minute,
+		Clock:      c,
+	}
+}
+
+func TestOutbox_RetriesInOrder(t *testing.T) {
+	t.Parallel()
+
+	s, c := &flakySender{broken: true}, &alarmtest.Clock{}
+	opts := outboxOptions(t, c)
+
+	o, err := notify.OpenOutbox(s, opts, discard)
+	require.NoError(t, err)
+	t.Cleanup(func() { o.Close(context.Background()) })
+
+	require.NoError(t, o.Send(alarmMessage("door1", "opened")))
+	require.NoError(t, o.Send(message.New(message.KeyAlarmManual, message.Critical, "alarm", "actor", "alice")))
+
+	require.Eventually(t, func() bool { return s.tries() == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, 2, o.Pending())
+
+	// the queue is on disk while delivery fails
+	data, err := os.ReadFile(opts.Path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"key":"alarm.manual"`)
+
+	// backoff doubles up to the maximum: 1s, 2s, 4s, 4s
+	for i, d := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		require.Eventually(t, func() bool { return c.Scheduled() == 1 }, time.Second, time.Millisecond)
+
+		c.Advance(d - time.Millisecond)
+		assert.Equal(t, i+1, s.tries(), "retried before the backoff elapsed")
+
+		c.Advance(time.Millisecond)
+		require.Eventually(t, func() bool { return s.tries() == i+2 }, time.Second, time.Millisecond)
+	}
+
+	s.fix()
+	require.Eventually(t, func() bool { return c.Scheduled() == 1 }, time.Second, time.Millisecond)
+	c.Advance(4 * time.Second)
+
+	require.Eventually(t, func() bool { return o.Pending() == 0 }, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"door1: opened", "alarm: triggered manually by alice"}, s.messages())
+
+	data, err = os.ReadFile(opts.Path)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[]`, string(data))
+}
+
+func TestOutbox_DeliversAfterRestart(t *testing.T) {
+	t.Parallel()
+
+	opts := outboxOptions(t, &alarmtest.Clock{})
+	queued := time.Date(2025, 1, 1, 20, 0, 0, 0, time.Local)
+
+	opened, closed := alarmMessage("door1", "opened"), alarmMessage("door1", "closed")
+	opened.Time, closed.Time = queued, time.Now()
+
+	data, err := json.Marshal([]map[string]any{
+		{"id": 1, "message": opened},
+		{"id": 2, "message": closed},
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(opts.Path, data, 0o600))
+
+	s := &fakeSender{}
+
+	o, err := notify.OpenOutbox(s, opts, discard)
+	require.NoError(t, err)
+	t.Cleanup(func() { o.Close(context.Background()) })
+
+	require.Eventually(t, func() bool { return o.Pending() == 0 }, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"door1: opened (delayed, from 2025-01-01 20:00:00)", "door1: closed"}, s.messages())
+}
+
+func TestOutbox_DropsRejected(t *testing.T) {
+	t.Parallel()
+
+	s, c := &flakySender{reject: message.KeyDigest}, &alarmtest.Clock{}
+
+	o, err := notify.OpenOutbox(s, outboxOptions(t, c), discard)
+	require.NoError(t, err)
+	t.Cleanup(func() { o.Close(context.Background()) })
+
+	require.NoError(t, o.Send(message.New(message.KeyDigest, message.Info, "digest", "alarms", "*unbalanced")))
+	require.NoError(t, o.Send(alarmMessage("door1", "opened")))
+
+	// the alert behind the poison message is delivered without waiting for a retry
+	require.Eventually(t, func() bool { return o.Pending() == 0 }, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"door1: opened"}, s.messages())
+	assert.Equal(t, 2, s.tries())
+	assert.Zero(t, c.Scheduled())
+}
+
+func TestOutbox_HonoursRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	s, c := &flakySender{broken: true, retryAfter: 30 * time.Second}, &alarmtest.Clock{}
+
+	o, err := notify.OpenOutbox(s, outboxOptions(t, c), discard)
+	require.NoError(t, err)
+	t.Cleanup(func() { o.Close(context.Background()) })
+
+	require.NoError(t, o.Send(alarmMessage("door1", "opened")))
+	require.Eventually(t, func() bool { return c.Scheduled() == 1 }, time.Second, time.Millisecond)
+
+	// MaxBackoff is 4s, but the sender asked to wait longer
+	c.Advance(30*time.Second - time.Millisecond)
+	assert.Equal(t, 1, s.tries())
+
+	s.fix()
+	c.Advance(time.Millisecond)
+
+	require.Eventually(t, func() bool { return o.Pending() == 0 }, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"door1: opened"}, s.messages())
+}
+
+func TestOutbox_FullDropsLeastUrgent(t *testing.T) {
+	t.Parallel()
+
+	s, c := &flakySender{broken: true}, &alarmtest.Clock{}
+	opts := outboxOptions(t, c)
+	opts.Path = ""
+
+	o, err := notify.OpenOutbox(s, opts, discard)
+	require.NoError(t, err)
+	t.Cleanup(func() { o.Close(context.Background()) })
+
+	require.NoError(t, o.Send(alarmMessage("door1", "opened")))
+	require.Eventually(t, func() bool { return c.Scheduled() == 1 }, time.Second, time.Millisecond)
+
+	for i := range 1000 {
+		require.NoError(t, o.Send(message.New(message.KeyDisarmed, message.Info, "alarm", "actor", strconv.Itoa(i))))
+	}
+
+	assert.Equal(t, 1000, o.Pending())
+
+	s.fix()
+	c.Advance(time.Second)
+
+	require.Eventually(t, func() bool { return o.Pending() == 0 }, time.Second, time.Millisecond)
+
+	// the alert is kept, the oldest info message is dropped
+	msgs := s.messages()
+	require.Len(t, msgs, 1000)
+	assert.Equal(t, "door1: opened", msgs[0])
+	assert.Equal(t, "alarm: disarmed by 1", msgs[1])
+}
+
+func TestOutbox_CloseDeliversQueued(t *testing.T) {
+	t.Parallel()
+
+	s, c := &flakySender{broken: true}, &alarmtest.Clock{}
+
+	o, err := notify.OpenOutbox(s, outboxOptions(t, c), discard)
+	require.NoError(t, err)
+
+	require.NoError(t, o.Send(alarmMessage("door1", "opened")))
+	require.NoError(t, o.Send(alarmMessage("door2", "opened")))
+	require.Eventually(t, func() bool { return c.Scheduled() == 1 }, time.Second, time.Millisecond)
+
+	// the connection is back, but the retry is a second away
+	s.fix()
+	require.NoError(t, o.Close(context.Background()))
+
+	assert.Equal(t, []string{"door1: opened", "door2: opened"}, s.messages())
+	assert.Zero(t, o.Pending())
+}
+
+func TestOutbox_CloseGivesUp(t *testing.T) {
+	t.Parallel()
+
+	s, c := &flakySender{broken: true}, &alarmtest.Clock{}
+
+	o, err := notify.OpenOutbox(s, outboxOptions(t, c), discard)
+	require.NoError(t, err)
+
+	require.NoError(t, o.Send(alarmMessage("door1", "opened")))
+	require.Eventually(t, func() bool { return c.Scheduled() == 1 }, time.Second, time.Millisecond)
+
+	require.NoError(t, o.Close(context.Background()))
+	assert.Equal(t, 2, s.tries(), "one more attempt is made before stopping")
+	assert.Equal(t, 1, o.Pending())
+}
+
+func TestOutbox_CloseTimeout(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	s := &blockingSender{release: release}
+
+	opts := outboxOptions(t, &alarmtest.Clock{})
+	opts.Path = ""
+
+	o, err := notify.OpenOutbox(s, opts, discard)
+	require.NoError(t, err)
+
+	require.NoError(t, o.Send(alarmMessage("door1", "opened")))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	require.ErrorIs(t, o.Close(ctx), context.DeadlineExceeded)
+	assert.Equal(t, 1, o.Pending())
+	close(release)
+}
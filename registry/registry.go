@@ -0,0 +1,394 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/SuddenGunter/hsd/alarm"
+)
+
+var (
+	// ErrDeviceExists is returned when adding a device that is already monitored.
+	ErrDeviceExists = errors.New("device already exists")
+	// ErrDeviceNotFound is returned when changing a device that is not monitored.
+	ErrDeviceNotFound = errors.New("device not found")
+	// ErrInvalidDevice is returned when the device config is invalid.
+	ErrInvalidDevice = errors.New("invalid device")
+)
+
+// DeviceSet is a component that tracks the list of monitored devices.
+type DeviceSet interface {
+	AddDevice(name string)
+	RemoveDevice(name string)
+}
+
+type modeSetter interface {
+	SetDeviceModes(device string, modes []alarm.Mode)
+}
+
+// Registry is the source of truth for the list of monitored devices.
+// It propagates runtime changes to all components tracking devices and persists them to a file.
+type Registry struct {
+	mux     *sync.Mutex
+	devices []alarm.DeviceConfig
+	// config is the configured device list the devices are based on.
+	config []alarm.DeviceConfig
+	path   string
+
+	modes modeSetter
+	sets  []DeviceSet
+
+	l *slog.Logger
+}
+
+// state is the content of the file. Config is the configured device list when it was saved,
+// so configuration changes made since then are applied on top of changes made via API.
+type state struct {
+	Config  []alarm.DeviceConfig `json:"config"`
+	Devices []alarm.DeviceConfig `json:"devices"`
+}
+
+// Open loads devices from the file at path and applies changes of the configured devices since it was saved.
+// If the file does not exist, the configured devices are used. Empty path disables persistence.
+func Open(path string, config []alarm.DeviceConfig, l *slog.Logger) (*Registry, error) {
+	r := &Registry{
+		mux:     &sync.Mutex{},
+		devices: slices.Clone(config),
+		config:  slices.Clone(config),
+		path:    path,
+		l:       l,
+	}
+
+	if path == "" {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("registry: %w", err)
+	}
+
+	var st state
+
+	// files of older versions hold only the device list, without the config it was based on
+	if err := json.Unmarshal(data, &st.Devices); err != nil {
+		if err := json.Unmarshal(data, &st); err != nil {
+			return nil, fmt.Errorf("registry: %s: %w", path, err)
+		}
+	} else {
+		st.Config = config
+	}
+
+	seen := make(map[string]struct{}, len(st.Devices))
+
+	for _, d := range st.Devices {
+		if err := Validate(d); err != nil {
+			return nil, fmt.Errorf("registry: %s: %w", path, err)
+		}
+
+		if _, ok := seen[d.Name]; ok {
+			return nil, fmt.Errorf("registry: %s: %w: %s", path, ErrDeviceExists, d.Name)
+		}
+
+		seen[d.Name] = struct{}{}
+	}
+
+	r.devices = merge(st.Devices, st.Config, config)
+
+	l.Info("device list loaded from file", "path", path, "devices", len(r.devices))
+
+	return r, nil
+}
+
+// Attach registers components that must be kept in sync with the registry.
+// Must be called before any changes are made.
+func (r *Registry) Attach(modes modeSetter, sets ...DeviceSet) {
+	r.modes = modes
+	r.sets = sets
+}
+
+// Devices returns all monitored devices.
+func (r *Registry) Devices() []alarm.DeviceConfig {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	return slices.Clone(r.devices)
+}
+
+// Device returns the config of a monitored device.
+func (r *Registry) Device(name string) (alarm.DeviceConfig, bool) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	i := r.index(name)
+	if i < 0 {
+		return alarm.DeviceConfig{}, false
+	}
+
+	return r.devices[i], true
+}
+
+// Names returns names of all monitored devices.
+func (r *Registry) Names() []string {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	names := make([]string, 0, len(r.devices))
+	for _, d := range r.devices {
+		names = append(names, d.Name)
+	}
+
+	return names
+}
+
+// DeviceModes returns modes of devices that are not monitored in all modes.
+func (r *Registry) DeviceModes() map[string][]alarm.Mode {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	modes := make(map[string][]alarm.Mode)
+
+	for _, d := range r.devices {
+		if len(d.Modes) > 0 {
+			modes[d.Name] = d.Modes
+		}
+	}
+
+	return modes
+}
+
+// Add starts monitoring a new device.
+func (r *Registry) Add(d alarm.DeviceConfig) error {
+	if err := Validate(d); err != nil {
+		return err
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if r.index(d.Name) >= 0 {
+		return fmt.Errorf("%w: %s", ErrDeviceExists, d.Name)
+	}
+
+	if err := r.save(append(slices.Clone(r.devices), d)); err != nil {
+		return err
+	}
+
+	r.devices = append(r.devices, d)
+
+	// modes go first, so the device is not monitored in the wrong mode even for a moment
+	r.modes.SetDeviceModes(d.Name, d.Modes)
+
+	for _, s := range r.sets {
+		s.AddDevice(d.Name)
+	}
+
+	return nil
+}
+
+// Update changes the config of a monitored device.
+func (r *Registry) Update(d alarm.DeviceConfig) error {
+	if err := Validate(d); err != nil {
+		return err
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	i := r.index(d.Name)
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrDeviceNotFound, d.Name)
+	}
+
+	devices := slices.Clone(r.devices)
+	devices[i] = d
+
+	if err := r.save(devices); err != nil {
+		return err
+	}
+
+	r.devices = devices
+	r.modes.SetDeviceModes(d.Name, d.Modes)
+
+	return nil
+}
+
+// Remove stops monitoring the device.
+func (r *Registry) Remove(name string) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	i := r.index(name)
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrDeviceNotFound, name)
+	}
+
+	devices := slices.Delete(slices.Clone(r.devices), i, i+1)
+
+	if err := r.save(devices); err != nil {
+		return err
+	}
+
+	r.devices = devices
+
+	for _, s := range r.sets {
+		s.RemoveDevice(name)
+	}
+
+	r.modes.SetDeviceModes(name, nil)
+
+	return nil
+}
+
+// Reconcile applies changes of the configured device list, e.g. after the config file changed.
+// Devices added, changed or removed via API are left alone, unless the config changes a device with the same name.
+func (r *Registry) Reconcile(config []alarm.DeviceConfig) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	prev := r.devices
+	devices := merge(prev, r.config, config)
+
+	if err := r.saveConfig(devices, config); err != nil {
+		return err
+	}
+
+	r.devices, r.config = devices, slices.Clone(config)
+
+	for _, d := range devices {
+		i := slices.IndexFunc(prev, func(p alarm.DeviceConfig) bool { return p.Name == d.Name })
+		if i >= 0 && same(prev[i], d) {
+			continue
+		}
+
+		// modes go first, so the device is not monitored in the wrong mode even for a moment
+		r.modes.SetDeviceModes(d.Name, d.Modes)
+
+		if i < 0 {
+			for _, s := range r.sets {
+				s.AddDevice(d.Name)
+			}
+		}
+	}
+
+	for _, p := range prev {
+		if slices.ContainsFunc(devices, func(d alarm.DeviceConfig) bool { return d.Name == p.Name }) {
+			continue
+		}
+
+		for _, s := range r.sets {
+			s.RemoveDevice(p.Name)
+		}
+
+		r.modes.SetDeviceModes(p.Name, nil)
+	}
+
+	return nil
+}
+
+// merge applies changes between two versions of the configured device list to devices.
+// Changed devices that are not in devices any more were removed via API and are not added back.
+func merge(devices, prev, next []alarm.DeviceConfig) []alarm.DeviceConfig {
+	res := slices.Clone(devices)
+
+	for _, d := range next {
+		i := slices.IndexFunc(prev, func(p alarm.DeviceConfig) bool { return p.Name == d.Name })
+		j := slices.IndexFunc(res, func(r alarm.DeviceConfig) bool { return r.Name == d.Name })
+
+		switch {
+		case i >= 0 && same(prev[i], d):
+		case j >= 0:
+			res[j] = d
+		case i < 0:
+			res = append(res, d)
+		}
+	}
+
+	for _, p := range prev {
+		if !slices.ContainsFunc(next, func(d alarm.DeviceConfig) bool { return d.Name == p.Name }) {
+			res = slices.DeleteFunc(res, func(d alarm.DeviceConfig) bool { return d.Name == p.Name })
+		}
+	}
+
+	return res
+}
+
+func same(a, b alarm.DeviceConfig) bool {
+	return slices.Equal(a.Modes, b.Modes) && a.Label == b.Label && a.Location == b.Location
+}
+
+// Validate checks the device config.
+func Validate(d alarm.DeviceConfig) error {
+	if d.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidDevice)
+	}
+
+	// names are used in mqtt topics
+	if strings.ContainsAny(d.Name, "/#+") {
+		return fmt.Errorf("%w: name must not contain '/', '#' or '+'", ErrInvalidDevice)
+	}
+
+	for _, m := range d.Modes {
+		if _, err := alarm.ParseMode(string(m)); err != nil || m == "" {
+			return fmt.Errorf("%w: unknown mode %q", ErrInvalidDevice, m)
+		}
+	}
+
+	return nil
+}
+
+// index must be called with r.mux held.
+func (r *Registry) index(name string) int {
+	return slices.IndexFunc(r.devices, func(d alarm.DeviceConfig) bool { return d.Name == name })
+}
+
+// save atomically writes devices to the file, so the change is not applied if it can't be persisted.
+// Must be called with r.mux held.
+func (r *Registry) save(devices []alarm.DeviceConfig) error {
+	return r.saveConfig(devices, r.config)
+}
+
+// saveConfig is save with the configured device list the devices are based on.
+func (r *Registry) saveConfig(devices, config []alarm.DeviceConfig) error {
+	if r.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(state{Config: config, Devices: devices}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("registry: save: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".*")
+	if err != nil {
+		return fmt.Errorf("registry: save: %w", err)
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+
+		return fmt.Errorf("registry: save: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("registry: save: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return fmt.Errorf("registry: save: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package registry_test
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SuddenGunter/hsd/alarm"
+	"github.com/SuddenGunter/hsd/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSet struct {
+	devices map[string]struct{}
+}
+
+func (f *fakeSet) AddDevice(name string)    { f.devices[name] = struct{}{} }
+func (f *fakeSet) RemoveDevice(name string) { delete(f.devices, name) }
+
+type fakeModes struct {
+	modes map[string][]alarm.Mode
+}
+
+func (f *fakeModes) SetDeviceModes(name string, modes []alarm.Mode) {
+	if len(modes) == 0 {
+		delete(f.modes, name)
+		return
+	}
+
+	f.modes[name] = modes
+}
+
+func discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestRegistry_ChangesArePropagatedAndPersisted(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "devices.json")
+
+	r, err := registry.Open(path, []alarm.DeviceConfig{{Name: "door1"}}, discard())
+	require.NoError(t, err)
+
+	modes := &fakeModes{modes: map[string][]alarm.Mode{}}
+	set := &fakeSet{devices: map[string]struct{}{"door1": {}}}
+	r.Attach(modes, set)
+
+	require.NoError(t, r.Add(alarm.DeviceConfig{Name: "window1", Modes: []alarm.Mode{alarm.ModeAway}}))
+	require.NoError(t, r.Update(alarm.DeviceConfig{Name: "door1", Modes: []alarm.Mode{alarm.ModeNight}}))
+	require.NoError(t, r.Remove("door1"))
+
+	assert.Equal(t, map[string]struct{}{"window1": {}}, set.devices)
+	assert.Equal(t, map[string][]alarm.Mode{"window1": {alarm.ModeAway}}, modes.modes)
+
+	r, err = registry.Open(path, nil, discard())
+	require.NoError(t, err)
+	assert.Equal(t, []alarm.DeviceConfig{{Name: "window1", Modes: []alarm.Mode{alarm.ModeAway}}}, r.Devices())
+}
+
+func TestRegistry_Errors(t *testing.T) {
+	t.Parallel()
+
+	r, err := registry.Open("", []alarm.DeviceConfig{{Name: "door1"}}, discard())
+	require.NoError(t, err)
+
+	r.Attach(&fakeModes{modes: map[string][]alarm.Mode{}})
+
+	require.ErrorIs(t, r.Add(alarm.DeviceConfig{Name: "door1"}), registry.ErrDeviceExists)
+	require.ErrorIs(t, r.Add(alarm.DeviceConfig{Name: "a/b"}), registry.ErrInvalidDevice)
+	require.ErrorIs(t, r.Add(alarm.DeviceConfig{Name: "door2", Modes: []alarm.Mode{"vacation"}}), registry.ErrInvalidDevice)
+	require.ErrorIs(t, r.Update(alarm.DeviceConfig{Name: "door2"}), registry.ErrDeviceNotFound)
+	require.ErrorIs(t, r.Remove("door2"), registry.ErrDeviceNotFound)
+	assert.Equal(t, []string{"door1"}, r.Names())
+}
+
+func TestRegistry_Reconcile(t *testing.T) {
+	t.Parallel()
+
+	prev := []alarm.DeviceConfig{{Name: "door1"}, {Name: "door2"}}
+
+	r, err := registry.Open("", prev, discard())
+	require.NoError(t, err)
+
+	r.Attach(&fakeModes{modes: map[string][]alarm.Mode{}})
+
+	// added via API, must survive the reload
+	require.NoError(t, r.Add(alarm.DeviceConfig{Name: "window1"}))
+
+	next := []alarm.DeviceConfig{{Name: "door1", Modes: []alarm.Mode{alarm.ModeAway}}, {Name: "door3"}}
+	require.NoError(t, r.Reconcile(next))
+
+	assert.Equal(t, []alarm.DeviceConfig{
+		{Name: "door1", Modes: []alarm.Mode{alarm.ModeAway}},
+		{Name: "window1"},
+		{Name: "door3"},
+	}, r.Devices())
+}
+
+func TestRegistry_ConfigChangesApplyAfterRestart(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "devices.json")
+	config := []alarm.DeviceConfig{{Name: "door1"}, {Name: "door2"}}
+
+	r, err := registry.Open(path, config, discard())
+	require.NoError(t, err)
+
+	r.Attach(&fakeModes{modes: map[string][]alarm.Mode{}})
+
+	require.NoError(t, r.Add(alarm.DeviceConfig{Name: "window1"}))
+	require.NoError(t, r.Remove("door2"))
+
+	// a reload is saved as well, but does not pin the config
+	config = append(config, alarm.DeviceConfig{Name: "door3"})
+	require.NoError(t, r.Reconcile(config))
+
+	// the config changes while hsd is stopped
+	config = []alarm.DeviceConfig{{Name: "door1", Label: "Front door"}, {Name: "door2"}, {Name: "garage"}}
+
+	r, err = registry.Open(path, config, discard())
+	require.NoError(t, err)
+
+	assert.Equal(t, []alarm.DeviceConfig{
+		{Name: "door1", Label: "Front door"},
+		{Name: "window1"},
+		{Name: "garage"},
+	}, r.Devices(), "door2 stays removed via API, door3 is removed from the config")
+}
+
+func TestRegistry_OpenDeviceListFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "devices.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[{"name":"window1"}]`), 0o600))
+
+	r, err := registry.Open(path, []alarm.DeviceConfig{{Name: "door1"}}, discard())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"window1"}, r.Names())
+}
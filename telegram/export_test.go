@@ -0,0 +1,4 @@
+package telegram
+
+// HideToken exposes hideToken to tests.
+var HideToken = hideToken
@@ -1,42 +1,197 @@
 package telegram
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SuddenGunter/hsd/metrics"
+	"github.com/SuddenGunter/hsd/notify"
+	"github.com/SuddenGunter/hsd/notify/message"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/hashicorp/go-retryablehttp"
 )
 
+var (
+	notificationsTotal   = metrics.NewCounter("hsd_notifications_total", "Telegram notifications by delivery result.", "result")
+	notificationDuration = metrics.NewHistogram("hsd_notification_duration_seconds", "Telegram notification delivery latency, including retries.", metrics.DefaultBuckets)
+)
+
+// Options of the Notifier.
+type Options struct {
+	// Renderer renders messages, plain English text if nil.
+	Renderer *message.Renderer
+	// Silent are severities delivered without a sound.
+	Silent []message.Severity
+}
+
 // Notifier sends messages to a telegram chat.
 type Notifier struct {
-	chatID int64
-	bot    *tgbotapi.BotAPI
+	chatID   int64
+	bot      *tgbotapi.BotAPI
+	renderer *message.Renderer
+	silent   map[message.Severity]bool
+
+	mux          *sync.RWMutex
+	lastDelivery time.Time
+	lastErr      error
 
 	l *slog.Logger
 }
 
 // NewNotifier returns a new Notifier.
-func NewNotifier(tgBotToken string, chatID int64, l *slog.Logger) (*Notifier, error) {
+func NewNotifier(tgBotToken string, chatID int64, opts Options, l *slog.Logger) (*Notifier, error) {
+	bot, err := newBot(tgBotToken)
+	if err != nil {
+		return nil, fmt.Errorf("new notifier: %w", err)
+	}
+
+	if opts.Renderer == nil {
+		opts.Renderer, err = message.NewRenderer(message.English(), message.FormatText, nil)
+		if err != nil {
+			return nil, fmt.Errorf("new notifier: %w", err)
+		}
+	}
+
+	silent := make(map[message.Severity]bool, len(opts.Silent))
+	for _, s := range opts.Silent {
+		silent[s] = true
+	}
+
+	return &Notifier{chatID: chatID, bot: bot, renderer: opts.Renderer, silent: silent, mux: &sync.RWMutex{}, l: l}, nil
+}
+
+// Reconfigure switches the notifier to another bot or chat. On error, the previous configuration is kept.
+func (n *Notifier) Reconfigure(tgBotToken string, chatID int64) error {
+	bot, err := newBot(tgBotToken)
+	if err != nil {
+		return fmt.Errorf("reconfigure notifier: %w", err)
+	}
+
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	n.bot = bot
+	n.chatID = chatID
+
+	return nil
+}
+
+// Notify sends a message to specific telegram chat about the alarm event. Delivery errors are logged.
+func (n *Notifier) Notify(m message.Message) {
+	if err := n.Send(m); err != nil {
+		n.l.Error("telegram message delivery failed", "err", err)
+	}
+}
+
+// Send sends a message to specific telegram chat about the alarm event and returns the delivery error.
+func (n *Notifier) Send(m message.Message) error {
+	n.mux.RLock()
+	bot, chatID := n.bot, n.chatID
+	n.mux.RUnlock()
+
+	tgMsg := tgbotapi.NewMessage(chatID, "")
+	tgMsg.DisableNotification = n.silent[m.Severity]
+
+	text, err := n.renderer.Render(m)
+	if err != nil {
+		// a broken template must not lose the alarm
+		n.l.Error("message rendering failed, sending plain text", "key", m.Key, "err", err)
+
+		text = m.Device + ": " + m.String()
+	} else {
+		tgMsg.ParseMode = string(n.renderer.Format())
+	}
+
+	tgMsg.Text = text
+
+	start := time.Now()
+	_, err = bot.Send(tgMsg)
+
+	notificationDuration.Observe(time.Since(start).Seconds())
+
+	// transport errors include the request URL, which contains the token
+	err = hideToken(err, bot.Token)
+
+	n.mux.Lock()
+	n.lastDelivery = start
+	n.lastErr = err
+	n.mux.Unlock()
+
+	if err != nil {
+		notificationsTotal.Inc("failure")
+
+		return classify(err)
+	}
+
+	notificationsTotal.Inc("success")
+
+	return nil
+}
+
+// LastDelivery returns the time and the error of the last delivery attempt.
+// It returns zero time if nothing was sent yet.
+func (n *Notifier) LastDelivery() (time.Time, error) {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+
+	return n.lastDelivery, n.lastErr
+}
+
+// classify tells the outbox which delivery errors are worth retrying.
+func classify(err error) error {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) {
+		return fmt.Errorf("send: %w", err)
+	}
+
+	switch {
+	case apiErr.RetryAfter > 0:
+		return &notify.RetryAfterError{After: time.Duration(apiErr.RetryAfter) * time.Second, Err: fmt.Errorf("send: %w", err)}
+	// e.g. unparsable markup, a message that is too long or a chat the bot can't post to
+	case apiErr.Code == http.StatusBadRequest || apiErr.Code == http.StatusForbidden:
+		return fmt.Errorf("send: %w: %w", notify.ErrRejected, err)
+	default:
+		return fmt.Errorf("send: %w", err)
+	}
+}
+
+// newBot creates a bot client, checking the token with the telegram API.
+func newBot(tgBotToken string) (*tgbotapi.BotAPI, error) {
 	retryClient := retryablehttp.NewClient()
 	retryClient.RetryMax = 3
 
 	bot, err := tgbotapi.NewBotAPIWithClient(tgBotToken, tgbotapi.APIEndpoint, retryClient.StandardClient())
 	if err != nil {
-		return nil, fmt.Errorf("new notifier: %w", err)
+		return nil, fmt.Errorf("bot api: %w", hideToken(err, tgBotToken))
 	}
 
-	return &Notifier{chatID: chatID, bot: bot, l: l}, nil
+	return bot, nil
 }
 
-// Notify sends a message to specific telegram chat about the alarm event.
-func (n *Notifier) Notify(device, msg string) {
-	payload := fmt.Sprintf("🚨 %s: %s", device, msg)
-	tgMsg := tgbotapi.NewMessage(n.chatID, payload)
+// tokenError hides the bot token in the text of an error, e.g. of a *url.Error with an API URL.
+type tokenError struct {
+	err   error
+	token string
+}
 
-	_, err := n.bot.Send(tgMsg)
-	if err != nil {
-		n.l.Error("telegram message delivery failed", "err", err)
-		return
+func (e *tokenError) Error() string {
+	return strings.ReplaceAll(e.err.Error(), e.token, "[redacted]")
+}
+
+func (e *tokenError) Unwrap() error {
+	return e.err
+}
+
+func hideToken(err error, token string) error {
+	if err == nil || token == "" {
+		return err
 	}
+
+	return &tokenError{err: err, token: token}
 }
@@ -0,0 +1,31 @@
+package telegram_test
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/SuddenGunter/hsd/telegram"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHideToken(t *testing.T) {
+	t.Parallel()
+
+	const token = "123456:ABC-DEF1234"
+
+	err := telegram.HideToken(&url.Error{
+		Op:  "Post",
+		URL: "https://api.telegram.org/bot" + token + "/sendMessage",
+		Err: errors.New("POST https://api.telegram.org/bot" + token + "/sendMessage giving up after 4 attempt(s)"),
+	}, token)
+
+	assert.NotContains(t, err.Error(), token)
+	assert.Contains(t, err.Error(), "https://api.telegram.org/bot[redacted]/sendMessage")
+
+	var urlErr *url.Error
+	require.ErrorAs(t, err, &urlErr)
+
+	assert.NoError(t, telegram.HideToken(nil, token))
+}
@@ -0,0 +1,174 @@
+"use strict";
+
+const refreshInterval = 5000;
+const tokenKey = "hsd.token";
+
+const $ = (id) => document.getElementById(id);
+
+async function api(method, path, body) {
+  const headers = {};
+  const token = localStorage.getItem(tokenKey);
+  if (token) {
+    headers["Authorization"] = "Bearer " + token;
+  }
+  if (body !== undefined) {
+    headers["Content-Type"] = "application/json";
+  }
+
+  const resp = await fetch(path, {
+    method,
+    headers,
+    body: body === undefined ? undefined : JSON.stringify(body),
+  });
+
+  if (!resp.ok) {
+    // API errors are {"status": 400, "error": "message"}
+    const body = await resp.json().catch(() => ({}));
+    const err = new Error(`${method} ${path}: ${resp.status} ${body.error || resp.statusText}`);
+    err.body = body;
+    throw err;
+  }
+
+  return resp.status === 204 ? null : resp.json();
+}
+
+function showError(err) {
+  $("error").textContent = err ? err.message : "";
+  $("error").hidden = !err;
+}
+
+function formatTime(value) {
+  return value ? new Date(value).toLocaleString() : "never";
+}
+
+function renderAlarm(alarm) {
+  const state = $("state");
+  state.textContent = alarm.state.replace("_", " ");
+  state.className = "state-" + alarm.state;
+
+  $("disarm").disabled = alarm.state === "disarmed";
+}
+
+function renderDevices(devices) {
+  const tiles = devices.map((d) => {
+    const tile = document.createElement("div");
+    tile.className = "tile";
+
+    let status = "closed";
+    if (d.bypass) {
+      tile.classList.add("bypassed");
+    }
+
+    if (!d.available) {
+      status = "offline";
+      tile.classList.add("offline");
+    } else if (d.opened) {
+      status = "open";
+      tile.classList.add("open");
+    }
+
+    const name = document.createElement("div");
+    name.className = "name";
+    name.textContent = d.name;
+
+    const state = document.createElement("div");
+    state.textContent = status;
+
+    const meta = document.createElement("div");
+    meta.className = "meta";
+    meta.textContent = `battery ${d.battery}% · updated ${formatTime(d.lastUpdated)}`;
+    if (d.bypass) {
+      meta.textContent += d.bypass.until ? ` · bypassed until ${formatTime(d.bypass.until)}` : " · bypassed until disarmed";
+    }
+
+    tile.append(name, state, meta);
+
+    return tile;
+  });
+
+  $("devices").replaceChildren(...tiles);
+}
+
+function renderAlarms(events) {
+  const items = events.map((e) => {
+    const li = document.createElement("li");
+    const time = document.createElement("time");
+    time.dateTime = e.time;
+    time.textContent = formatTime(e.time);
+
+    li.append(time, document.createTextNode(e.device ? `${e.device}: ${e.message}` : e.message));
+
+    return li;
+  });
+
+  if (items.length === 0) {
+    const li = document.createElement("li");
+    li.textContent = "No alarms";
+    items.push(li);
+  }
+
+  $("alarms").replaceChildren(...items);
+}
+
+async function refresh() {
+  try {
+    const [alarm, devices, alarms] = await Promise.all([
+      api("GET", "/alarm"),
+      api("GET", "/devices"),
+      api("GET", "/events?type=alarm&limit=10"),
+    ]);
+
+    renderAlarm(alarm);
+    renderDevices(devices);
+    renderAlarms(alarms.events);
+    showError(null);
+  } catch (err) {
+    showError(err);
+  }
+}
+
+async function setAlarm(body) {
+  try {
+    await api("POST", "/alarm", body);
+    await refresh();
+  } catch (err) {
+    // arming was refused because devices are open or offline
+    const devices = err.body && err.body.devices;
+    if (devices && !body.force) {
+      const list = devices.map((d) => `${d.name} (${d.problem})`).join(", ");
+      if (confirm(`Not ready to arm: ${list}.\n\nArm anyway and bypass them until disarmed?`)) {
+        return setAlarm({ ...body, force: true });
+      }
+    }
+
+    showError(err);
+  }
+}
+
+document.querySelectorAll("[data-mode]").forEach((b) => {
+  b.addEventListener("click", () => setAlarm({ enabled: true, mode: b.dataset.mode }));
+});
+
+$("disarm").addEventListener("click", () => setAlarm({ enabled: false }));
+
+$("settings-toggle").addEventListener("click", () => {
+  $("token").value = localStorage.getItem(tokenKey) || "";
+  $("settings").hidden = !$("settings").hidden;
+});
+
+$("settings").addEventListener("submit", (ev) => {
+  ev.preventDefault();
+
+  const token = $("token").value.trim();
+  if (token) {
+    localStorage.setItem(tokenKey, token);
+  } else {
+    localStorage.removeItem(tokenKey);
+  }
+
+  $("settings").hidden = true;
+  refresh();
+});
+
+refresh();
+setInterval(refresh, refreshInterval);
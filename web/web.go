@@ -0,0 +1,31 @@
+// Package web serves the built-in dashboard.
+// The dashboard is a static single-page app that only uses the JSON API, so it needs no server-side state.
+package web
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed static
+var static embed.FS
+
+// Handler serves the dashboard files.
+func Handler() http.Handler {
+	files, err := fs.Sub(static, "static")
+	if err != nil {
+		// static is embedded at build time, so this can only fail if the directive above is wrong
+		panic(err)
+	}
+
+	fileServer := http.FileServerFS(files)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", "default-src 'self'")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", "no-cache")
+
+		fileServer.ServeHTTP(w, r)
+	})
+}
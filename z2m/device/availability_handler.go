@@ -4,12 +4,13 @@ import (
 	"context"
 	"log/slog"
 
+	"github.com/SuddenGunter/hsd/alarm"
 	"github.com/SuddenGunter/hsd/z2m"
 )
 
 type deviceNotifier interface {
 	SetAvailability(ctx context.Context, device string, available bool)
-	SetOpened(ctx context.Context, device string, opened bool)
+	SetData(ctx context.Context, device string, data alarm.SensorData)
 }
 
 // AvailabilityHandler handles availability messages from zigbee2mqtt.
@@ -34,5 +35,6 @@ func (h *AvailabilityHandler) Handle(ctx context.Context, msg z2m.Msg) {
 		h.deviceNotifier.SetAvailability(ctx, msg.Device, false)
 	default:
 		h.l.Error("failed to parse device availability", "device", msg.Device, "payload", string(msg.Payload))
+		parseErrorsTotal.Inc("availability")
 	}
 }
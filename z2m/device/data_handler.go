@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"log/slog"
 
+	"github.com/SuddenGunter/hsd/alarm"
 	"github.com/SuddenGunter/hsd/z2m"
 )
 
@@ -30,11 +31,17 @@ func (h *DataHandler) Handle(ctx context.Context, msg z2m.Msg) {
 	err := json.Unmarshal(msg.Payload, &sensorMsg)
 	if err != nil {
 		h.l.Error("failed to unmarshal door sensor message", "err", err)
+		parseErrorsTotal.Inc("data")
+
 		return
 	}
 
-	// contact is true when the door is closed
-	h.deviceNotifier.SetOpened(ctx, msg.Device, !sensorMsg.Contact)
+	h.deviceNotifier.SetData(ctx, msg.Device, alarm.SensorData{
+		// contact is true when the door is closed
+		Opened:      !sensorMsg.Contact,
+		Battery:     sensorMsg.Battery,
+		LinkQuality: sensorMsg.LinkQuality,
+	})
 }
 
 type doorSensorMsg struct {
@@ -0,0 +1,5 @@
+package device
+
+import "github.com/SuddenGunter/hsd/metrics"
+
+var parseErrorsTotal = metrics.NewCounter("hsd_mqtt_parse_errors_total", "zigbee2mqtt messages that failed to parse.", "handler")
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/SuddenGunter/hsd/app/config"
+	"github.com/SuddenGunter/hsd/hass"
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
 
@@ -19,6 +20,11 @@ func Connect(cfg *config.Config) (mqtt.Client, error) {
 	opts.SetPassword(cfg.MQTT.Password)
 	opts.SetConnectTimeout(10 * time.Second)
 
+	if cfg.HomeAssistant.Enabled {
+		// let home assistant know when hsd goes away unexpectedly
+		opts.SetWill(hass.StatusTopic(cfg.HomeAssistant.TopicPrefix), "offline", 1, true)
+	}
+
 	client := mqtt.NewClient(opts)
 	if token := client.Connect(); token.Wait() && token.Error() != nil {
 		return nil, fmt.Errorf("mqtt: conn: %w", token.Error())
@@ -4,11 +4,16 @@ import (
 	"context"
 	"log/slog"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/SuddenGunter/hsd/metrics"
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
 
+var messagesTotal = metrics.NewCounter("hsd_mqtt_messages_total", "zigbee2mqtt messages received for monitored devices.", "device", "type")
+
 // Msg represents a message from zigbee2mqtt.
 type Msg struct {
 	Payload []byte
@@ -24,7 +29,12 @@ type Zigbee2MQTTListener struct {
 	client              mqtt.Client
 	dataHandler         msgHandler
 	availabilityHandler msgHandler
-	allowedDevices      map[string]struct{}
+
+	mux            *sync.RWMutex
+	allowedDevices map[string]struct{}
+
+	// lastMessage is the unix time in nanoseconds of the last message received from zigbee2mqtt
+	lastMessage *atomic.Int64
 
 	l *slog.Logger
 }
@@ -46,7 +56,31 @@ func NewZigbee2MQTTListener(
 		l.Error("no devices were enabled for zigbee2mqtt listener")
 	}
 
-	return &Zigbee2MQTTListener{client: client, dataHandler: dataHandler, availabilityHandler: availabilityHandler, allowedDevices: m, l: l}
+	return &Zigbee2MQTTListener{
+		client:              client,
+		dataHandler:         dataHandler,
+		availabilityHandler: availabilityHandler,
+		mux:                 &sync.RWMutex{},
+		allowedDevices:      m,
+		lastMessage:         &atomic.Int64{},
+		l:                   l,
+	}
+}
+
+// AddDevice starts forwarding messages of the device to handlers.
+func (listener *Zigbee2MQTTListener) AddDevice(device string) {
+	listener.mux.Lock()
+	defer listener.mux.Unlock()
+
+	listener.allowedDevices[device] = struct{}{}
+}
+
+// RemoveDevice stops forwarding messages of the device to handlers.
+func (listener *Zigbee2MQTTListener) RemoveDevice(device string) {
+	listener.mux.Lock()
+	defer listener.mux.Unlock()
+
+	delete(listener.allowedDevices, device)
 }
 
 // Subscribe to the zigbee2mqtt/# topic.
@@ -59,6 +93,8 @@ func (listener *Zigbee2MQTTListener) Subscribe() {
 func (listener *Zigbee2MQTTListener) onMessage(_ mqtt.Client, msg mqtt.Message) {
 	defer msg.Ack()
 
+	listener.lastMessage.Store(time.Now().UnixNano())
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -73,12 +109,13 @@ func (listener *Zigbee2MQTTListener) onMessage(_ mqtt.Client, msg mqtt.Message)
 
 	if strings.HasSuffix(topic, "/availability") {
 		device := strings.TrimSuffix(topic, "/availability")
-		if _, ok := listener.allowedDevices[device]; !ok {
+		if !listener.allowed(device) {
 			listener.l.Debug("device not allowed", "device", device)
 
 			return
 		}
 
+		messagesTotal.Inc(device, "availability")
 		listener.availabilityHandler.Handle(ctx, Msg{
 			Payload: msg.Payload(),
 			Device:  device,
@@ -87,14 +124,35 @@ func (listener *Zigbee2MQTTListener) onMessage(_ mqtt.Client, msg mqtt.Message)
 		return
 	}
 
-	if _, ok := listener.allowedDevices[topic]; !ok {
+	if !listener.allowed(topic) {
 		listener.l.Debug("device not allowed", "device", topic)
 
 		return
 	}
 
+	messagesTotal.Inc(topic, "data")
 	listener.dataHandler.Handle(ctx, Msg{
 		Payload: msg.Payload(),
 		Device:  topic,
 	})
 }
+
+// LastMessage returns the time of the last message received from zigbee2mqtt, including bridge messages.
+// It returns zero time if no messages were received yet.
+func (listener *Zigbee2MQTTListener) LastMessage() time.Time {
+	ns := listener.lastMessage.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, ns)
+}
+
+func (listener *Zigbee2MQTTListener) allowed(device string) bool {
+	listener.mux.RLock()
+	defer listener.mux.RUnlock()
+
+	_, ok := listener.allowedDevices[device]
+
+	return ok
+}